@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"github.com/holiman/uint256"
+)
+
+// CalcBaseFee returns the base fee of the child of parent. It rises when
+// the parent used more gas than its target and falls when it used less.
+func CalcBaseFee(config *GasConfig, parent *Header) *uint256.Int {
+	baseFee := new(uint256.Int).Set(&parent.BaseFee)
+	if config.ElasticityMultiplier == 0 || config.BaseFeeChangeDenominator == 0 {
+		return baseFee
+	}
+	gasTarget := parent.GasLimit / config.ElasticityMultiplier
+	if gasTarget == 0 || parent.GasUsed == gasTarget {
+		return baseFee
+	}
+
+	var gasDelta uint64
+	if parent.GasUsed > gasTarget {
+		gasDelta = parent.GasUsed - gasTarget
+	} else {
+		gasDelta = gasTarget - parent.GasUsed
+	}
+	// baseFee * gasDelta / target / denominator, saturating like the rise
+	delta, overflow := new(uint256.Int).MulDivOverflow(baseFee, uint256.NewInt(gasDelta), uint256.NewInt(gasTarget))
+	if overflow {
+		delta.SetAllOne()
+	}
+	delta.Div(delta, uint256.NewInt(config.BaseFeeChangeDenominator))
+
+	if parent.GasUsed > gasTarget {
+		// always rise a little, so a zero base fee can get going
+		if delta.IsZero() {
+			delta.SetOne()
+		}
+		if _, overflow := baseFee.AddOverflow(baseFee, delta); overflow {
+			baseFee.SetAllOne()
+		}
+		return baseFee
+	}
+	if baseFee.Lt(delta) {
+		return baseFee.Clear()
+	}
+	return baseFee.Sub(baseFee, delta)
+}
@@ -0,0 +1,44 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestCalcBaseFee(t *testing.T) {
+	// a gas limit of 200 gives a target of 100
+	config := &GasConfig{ElasticityMultiplier: 2, BaseFeeChangeDenominator: 8}
+	for i, test := range []struct {
+		config  *GasConfig
+		baseFee uint64
+		gasUsed uint64
+		want    uint64
+	}{
+		{config, 1000, 100, 1000},
+		{config, 1000, 200, 1125},
+		{config, 1000, 150, 1062},
+		{config, 1000, 0, 875},
+		{config, 1000, 50, 938},
+		{config, 0, 200, 1}, // a zero base fee still rises
+		{config, 1, 0, 1},
+		{&GasConfig{}, 1000, 200, 1000},
+	} {
+		parent := &Header{GasLimit: 200, GasUsed: test.gasUsed, BaseFee: *uint256.NewInt(test.baseFee)}
+		if have := CalcBaseFee(test.config, parent); have.Uint64() != test.want {
+			t.Errorf("test %d: base fee mismatch: have %v, want %d", i, have, test.want)
+		}
+	}
+
+	// a saturated base fee stays saturated and falls by its share
+	max := new(uint256.Int).SetAllOne()
+	parent := &Header{GasLimit: 200, GasUsed: 200, BaseFee: *max}
+	if have := CalcBaseFee(config, parent); !have.Eq(max) {
+		t.Errorf("rising base fee mismatch: have %v, want %v", have, max)
+	}
+	parent.GasUsed = 0
+	want := new(uint256.Int).Sub(max, new(uint256.Int).Div(max, uint256.NewInt(8)))
+	if have := CalcBaseFee(config, parent); !have.Eq(want) {
+		t.Errorf("falling base fee mismatch: have %v, want %v", have, want)
+	}
+}
@@ -2,11 +2,11 @@ package blockchain
 
 import (
 	"cxchain223/crypto/sha3"
-	"cxchain223/trie"
-	"cxchain223/txpool"
 	"cxchain223/types"
 	"cxchain223/utils/hash"
 	"cxchain223/utils/rlp"
+
+	"github.com/holiman/uint256"
 )
 
 type Header struct {
@@ -15,6 +15,10 @@ type Header struct {
 	Height     uint64
 	Coinbase   types.Address
 	Timestamp  uint64
+	Bloom      types.Bloom // union of the receipt blooms of the block
+	GasLimit   uint64
+	GasUsed    uint64
+	BaseFee    uint256.Int // least gas price of the block, see CalcBaseFee
 
 	Nonce uint64
 }
@@ -34,7 +38,16 @@ func NewHeader(parent Header) *Header {
 		Root:       parent.Root,
 		ParentHash: parent.Hash(),
 		Height:     parent.Height + 1,
+		GasLimit:   parent.GasLimit,
+	}
+}
+
+// GasUsed returns the gas used by all transactions of the body.
+func (body *Body) GasUsed() uint64 {
+	if len(body.Receiptions) == 0 {
+		return 0
 	}
+	return body.Receiptions[len(body.Receiptions)-1].CumulativeGasUsed
 }
 
 func NewBlock() *Body {
@@ -43,9 +56,3 @@ func NewBlock() *Body {
 		Receiptions:  make([]types.Receiption, 0),
 	}
 }
-
-type Blockchain struct {
-	CurrentHeader Header
-	Statedb       trie.ITrie
-	Txpool        txpool.TxPool
-}
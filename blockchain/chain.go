@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+	"cxchain223/trie"
+	"cxchain223/txpool"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrUnknownParent  = errors.New("unknown parent")
+	ErrInvalidHeight  = errors.New("invalid block height")
+	ErrInvalidBaseFee = errors.New("invalid base fee")
+)
+
+type Blockchain struct {
+	ChainID       uint64 // chain the senders of imported blocks are recovered for
+	CurrentHeader Header
+	Statedb       trie.ITrie
+	Txpool        txpool.TxPool
+	Gas           GasConfig
+
+	lock      sync.RWMutex
+	headers   map[hash.Hash]*Header
+	bodies    map[hash.Hash]*Body
+	canonical map[uint64]hash.Hash // height => hash of the block on the main chain
+}
+
+// NewBlockchain returns a chain holding only genesis. A genesis gas limit
+// below DefaultGasConfig.MinGasLimit, which no child could verify, is set
+// to DefaultGasConfig.GasLimit, so the hash is that of the adjusted header.
+func NewBlockchain(genesis Header, statedb trie.ITrie, pool txpool.TxPool) *Blockchain {
+	if genesis.GasLimit < DefaultGasConfig.MinGasLimit {
+		genesis.GasLimit = DefaultGasConfig.GasLimit
+	}
+	h := genesis.Hash()
+	return &Blockchain{
+		CurrentHeader: genesis,
+		Statedb:       statedb,
+		Txpool:        pool,
+		Gas:           DefaultGasConfig,
+		headers:       map[hash.Hash]*Header{h: &genesis},
+		bodies:        map[hash.Hash]*Body{h: NewBlock()},
+		canonical:     map[uint64]hash.Hash{genesis.Height: h},
+	}
+}
+
+// AddBlock stores a block whose parent is known. A block higher than the
+// current head becomes the new head, rewriting the main chain back to the
+// common ancestor if it is on a different branch, and the pool is reset to
+// it.
+func (chain *Blockchain) AddBlock(header *Header, body *Body) error {
+	// recovered at once and cached on the transactions, for the pool and
+	// tracers reading them back
+	txs := make([]*types.Transaction, len(body.Transactions))
+	for i := range body.Transactions {
+		txs[i] = &body.Transactions[i]
+	}
+	types.RecoverSenders(chain.ChainID, txs)
+
+	oldHead, newHead, err := chain.addBlock(header, body)
+	if err != nil {
+		return err
+	}
+	// outside the lock, the pool reads the chain back
+	if newHead != nil && chain.Txpool != nil {
+		chain.Txpool.Reset(oldHead, newHead)
+	}
+	return nil
+}
+
+// addBlock stores the block and returns the old and new pool heads if the
+// head changed.
+func (chain *Blockchain) addBlock(header *Header, body *Body) (*txpool.Head, *txpool.Head, error) {
+	chain.lock.Lock()
+	defer chain.lock.Unlock()
+
+	parent, ok := chain.headers[header.ParentHash]
+	if !ok {
+		return nil, nil, ErrUnknownParent
+	}
+	if header.Height != parent.Height+1 {
+		return nil, nil, ErrInvalidHeight
+	}
+	if err := chain.verifyGas(parent, header, body); err != nil {
+		return nil, nil, err
+	}
+	h := header.Hash()
+	chain.headers[h] = header
+	chain.bodies[h] = body
+
+	if header.Height <= chain.CurrentHeader.Height {
+		return nil, nil, nil
+	}
+	oldHead := chain.poolHead(&chain.CurrentHeader)
+	chain.setHead(header)
+	return oldHead, chain.poolHead(header), nil
+}
+
+// verifyGas checks the gas limit, gas used and base fee of header.
+func (chain *Blockchain) verifyGas(parent, header *Header, body *Body) error {
+	if err := VerifyGasLimit(&chain.Gas, parent.GasLimit, header.GasLimit); err != nil {
+		return err
+	}
+	if header.GasUsed > header.GasLimit {
+		return fmt.Errorf("%w: have %d, limit %d", ErrGasLimitExceeded, header.GasUsed, header.GasLimit)
+	}
+	if used := body.GasUsed(); header.GasUsed != used {
+		return fmt.Errorf("%w: have %d, want %d", ErrInvalidGasUsed, header.GasUsed, used)
+	}
+	if want := CalcBaseFee(&chain.Gas, parent); !header.BaseFee.Eq(want) {
+		return fmt.Errorf("%w: have %v, want %v", ErrInvalidBaseFee, &header.BaseFee, want)
+	}
+	return nil
+}
+
+func (chain *Blockchain) setHead(head *Header) {
+	for header := head; ; {
+		h := header.Hash()
+		if chain.canonical[header.Height] == h {
+			break
+		}
+		chain.canonical[header.Height] = h
+		parent, ok := chain.headers[header.ParentHash]
+		if !ok {
+			break
+		}
+		header = parent
+	}
+	chain.CurrentHeader = *head
+}
+
+// Head returns the header of the latest block on the main chain.
+func (chain *Blockchain) Head() *Header {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	head := chain.CurrentHeader
+	return &head
+}
+
+func (chain *Blockchain) GetHeaderByHash(h hash.Hash) *Header {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	return chain.headers[h]
+}
+
+// GetHeaderByNumber returns the header at the given height on the main chain.
+func (chain *Blockchain) GetHeaderByNumber(number uint64) *Header {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	h, ok := chain.canonical[number]
+	if !ok {
+		return nil
+	}
+	return chain.headers[h]
+}
+
+func (chain *Blockchain) GetBody(h hash.Hash) *Body {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	return chain.bodies[h]
+}
+
+// GetHead returns the block h as a pool head, nil if it is unknown.
+func (chain *Blockchain) GetHead(h hash.Hash) *txpool.Head {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	header, ok := chain.headers[h]
+	if !ok {
+		return nil
+	}
+	return chain.poolHead(header)
+}
+
+// GetTransactions returns the transactions of the block h.
+func (chain *Blockchain) GetTransactions(h hash.Hash) []types.Transaction {
+	chain.lock.RLock()
+	defer chain.lock.RUnlock()
+	if body, ok := chain.bodies[h]; ok {
+		return body.Transactions
+	}
+	return nil
+}
+
+// poolHead returns header as a pool head, with the base fee of its child.
+func (chain *Blockchain) poolHead(header *Header) *txpool.Head {
+	return &txpool.Head{
+		Hash:       header.Hash(),
+		ParentHash: header.ParentHash,
+		Height:     header.Height,
+		Root:       header.Root,
+		BaseFee:    *CalcBaseFee(&chain.Gas, header),
+	}
+}
@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"cxchain223/crypto"
+	"cxchain223/statdb"
+	"cxchain223/txpool"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// rootedStat serves a separate in-memory state per root.
+type rootedStat struct {
+	*statdb.CacheDB
+	roots map[hash.Hash]*statdb.CacheDB
+}
+
+func (s *rootedStat) SetStatRoot(root hash.Hash) {
+	s.CacheDB = s.roots[root]
+}
+
+func TestAddBlockResetsPool(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := types.PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey))
+	stat := &rootedStat{roots: make(map[hash.Hash]*statdb.CacheDB)}
+	// root gives the state after a block, with the nonce of sender at it
+	root := func(root hash.Hash, nonce uint64) hash.Hash {
+		state := statdb.NewMemoryDB()
+		state.Store(sender, types.Account{Amount: *uint256.NewInt(100000000), Nonce: nonce})
+		stat.roots[root] = state
+		return root
+	}
+	stat.CacheDB = stat.roots[root(hash.Hash{}, 0)]
+
+	chain := NewBlockchain(Header{}, nil, nil)
+	chain.ChainID = 1
+	pool := txpool.NewDefaultPool(1, stat, chain)
+	chain.Txpool = pool
+
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		tx := types.NewTx(&types.LegacyTx{To: &types.Address{0xaa}, Nonce: uint64(i + 1), Gas: 21000, GasPrice: *uint256.NewInt(1)})
+		txs[i], _ = types.SignTx(tx, 1, key)
+		if err := pool.NewTx(txs[i]); err != nil {
+			t.Fatalf("tx %d: unexpected error: %v", i, err)
+		}
+	}
+	addBlock := func(parent *Header, stateRoot hash.Hash, txs ...*types.Transaction) *Header {
+		header := NewHeader(*parent)
+		header.Root = stateRoot
+		header.BaseFee = *CalcBaseFee(&chain.Gas, parent)
+		body := NewBlock()
+		for _, tx := range txs {
+			body.Transactions = append(body.Transactions, *tx)
+		}
+		if err := chain.AddBlock(header, body); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return header
+	}
+	checkPending := func(want int) {
+		t.Helper()
+		if pending, queued := pool.Stats(); pending != want || queued != 0 {
+			t.Fatalf("stats mismatch: have %d/%d, want %d/0", pending, queued, want)
+		}
+	}
+	genesis := chain.Head()
+
+	// the head moves to a block with the first two txs
+	old := addBlock(genesis, root(hash.Hash{0xa1}, 2), txs[0], txs[1])
+	checkPending(1)
+
+	// a side branch only becomes the head once it is longer, then the tx
+	// it lacks is pending again
+	side := addBlock(genesis, root(hash.Hash{0xb1}, 1), txs[0])
+	checkPending(1)
+	head := addBlock(side, root(hash.Hash{0xb2}, 1))
+	if chain.Head().Hash() != head.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", chain.Head().Hash(), head.Hash())
+	}
+	if chain.GetHeaderByNumber(1).Hash() == old.Hash() {
+		t.Error("reorganized block still on the main chain")
+	}
+	checkPending(2)
+	for _, want := range []uint64{2, 3} {
+		if tx := pool.Pop(); tx == nil || tx.Nonce() != want {
+			t.Fatalf("pop mismatch: have %v, want nonce %d", tx, want)
+		}
+	}
+}
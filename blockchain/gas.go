@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidGasLimit  = errors.New("invalid gas limit")
+	ErrGasLimitExceeded = errors.New("gas used exceeds gas limit")
+	ErrInvalidGasUsed   = errors.New("invalid gas used")
+)
+
+// GasConfig sets how much gas blocks of a chain can hold and how the base
+// fee follows the demand for it.
+type GasConfig struct {
+	GasLimit    uint64 // gas limit block makers move towards
+	MinGasLimit uint64
+
+	// GasLimitBoundDivisor bounds the change of the gas limit between two
+	// blocks to 1/GasLimitBoundDivisor of the parent's limit. 0 keeps the
+	// gas limit fixed.
+	GasLimitBoundDivisor uint64
+
+	// ElasticityMultiplier sets the gas target of the base fee to the gas
+	// limit divided by it. 0 keeps the base fee fixed.
+	ElasticityMultiplier uint64
+
+	// BaseFeeChangeDenominator bounds the change of the base fee between
+	// two blocks to 1/BaseFeeChangeDenominator.
+	BaseFeeChangeDenominator uint64
+}
+
+var DefaultGasConfig = GasConfig{
+	GasLimit:                 30000000,
+	MinGasLimit:              5000,
+	GasLimitBoundDivisor:     1024,
+	ElasticityMultiplier:     2,
+	BaseFeeChangeDenominator: 8,
+}
+
+// CalcGasLimit returns the gas limit of a child of a block with the given
+// gas limit, moving as far towards config.GasLimit as the bound allows.
+func CalcGasLimit(config *GasConfig, parentGasLimit uint64) uint64 {
+	if config.GasLimitBoundDivisor == 0 {
+		return parentGasLimit
+	}
+	// stay strictly within the bound VerifyGasLimit checks, but move at
+	// least 1 so small limits are not stuck
+	delta := parentGasLimit / config.GasLimitBoundDivisor
+	if delta > 1 {
+		delta--
+	} else {
+		delta = 1
+	}
+	limit := parentGasLimit
+	desired := max(config.GasLimit, config.MinGasLimit)
+	if limit < desired {
+		limit = min(parentGasLimit+delta, desired)
+	} else if limit > desired {
+		limit = max(parentGasLimit-delta, desired)
+	}
+	return limit
+}
+
+// VerifyGasLimit checks that a block's gas limit is within the bound of
+// its parent's. A change of 1 is always within the bound.
+func VerifyGasLimit(config *GasConfig, parentGasLimit, headerGasLimit uint64) error {
+	var diff uint64
+	if parentGasLimit > headerGasLimit {
+		diff = parentGasLimit - headerGasLimit
+	} else {
+		diff = headerGasLimit - parentGasLimit
+	}
+	if config.GasLimitBoundDivisor == 0 {
+		if diff != 0 {
+			return fmt.Errorf("%w: have %d, want %d", ErrInvalidGasLimit, headerGasLimit, parentGasLimit)
+		}
+	} else if limit := parentGasLimit / config.GasLimitBoundDivisor; diff >= limit && diff > 1 {
+		return fmt.Errorf("%w: have %d, want %d within %d", ErrInvalidGasLimit, headerGasLimit, parentGasLimit, limit)
+	}
+	if headerGasLimit < config.MinGasLimit {
+		return fmt.Errorf("%w: have %d, minimum %d", ErrInvalidGasLimit, headerGasLimit, config.MinGasLimit)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"cxchain223/types"
+	"errors"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestCalcGasLimit(t *testing.T) {
+	config := &GasConfig{GasLimit: 20000, MinGasLimit: 5000, GasLimitBoundDivisor: 1024}
+	for i, test := range []struct {
+		parent, want uint64
+	}{
+		{20000, 20000},
+		{10240, 10249}, // up by 10240/1024 - 1
+		{19999, 20000}, // capped at the desired limit
+		{40960, 40921}, // down by 40960/1024 - 1
+		{20010, 20000},
+		{1000, 1001}, // at least 1 below the divisor
+		{0, 1},
+	} {
+		have := CalcGasLimit(config, test.parent)
+		if have != test.want {
+			t.Errorf("test %d: gas limit mismatch: have %d, want %d", i, have, test.want)
+		}
+		if err := VerifyGasLimit(config, test.parent, have); err != nil && have >= config.MinGasLimit {
+			t.Errorf("test %d: calculated limit does not verify: %v", i, err)
+		}
+	}
+	if have := CalcGasLimit(&GasConfig{GasLimit: 1}, 5000); have != 5000 {
+		t.Errorf("fixed gas limit mismatch: have %d", have)
+	}
+}
+
+func TestVerifyGasLimit(t *testing.T) {
+	config := &DefaultGasConfig
+	for i, test := range []struct {
+		parent, header uint64
+		ok             bool
+	}{
+		{10240, 10240, true},
+		{10240, 10249, true},
+		{10240, 10250, false},
+		{10240, 10231, true},
+		{10240, 10230, false},
+		{4000, 4000, false}, // below the minimum
+	} {
+		err := VerifyGasLimit(config, test.parent, test.header)
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("test %d: have %v, want ok %v", i, err, test.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidGasLimit) {
+			t.Errorf("test %d: error mismatch: %v", i, err)
+		}
+	}
+	// below the divisor the bound is zero, a change of 1 is still allowed
+	small := &GasConfig{GasLimitBoundDivisor: 1024}
+	if err := VerifyGasLimit(small, 1000, 1001); err != nil {
+		t.Errorf("step of 1 rejected: %v", err)
+	}
+	if err := VerifyGasLimit(small, 1000, 1002); !errors.Is(err, ErrInvalidGasLimit) {
+		t.Errorf("step of 2: error mismatch: have %v, want %v", err, ErrInvalidGasLimit)
+	}
+}
+
+func TestAddBlockGas(t *testing.T) {
+	genesis := Header{GasLimit: 10240, BaseFee: *uint256.NewInt(1000)}
+	newBody := func(gasUsed uint64) *Body {
+		body := NewBlock()
+		body.Receiptions = append(body.Receiptions, types.Receiption{CumulativeGasUsed: gasUsed})
+		return body
+	}
+
+	for i, test := range []struct {
+		gasLimit, gasUsed, bodyGas, baseFee uint64
+		err                                 error
+	}{
+		{10240, 100, 100, 875, nil},
+		{10250, 100, 100, 875, ErrInvalidGasLimit},
+		{10240, 100, 200, 875, ErrInvalidGasUsed},
+		{10240, 10241, 10241, 875, ErrGasLimitExceeded},
+		{10240, 100, 100, 1000, ErrInvalidBaseFee},
+	} {
+		chain := NewBlockchain(genesis, nil, nil)
+		header := NewHeader(genesis)
+		header.GasLimit = test.gasLimit
+		header.GasUsed = test.gasUsed
+		header.BaseFee = *uint256.NewInt(test.baseFee)
+		if err := chain.AddBlock(header, newBody(test.bodyGas)); !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+
+	// the base fee of a child follows the gas used by its parent
+	chain := NewBlockchain(genesis, nil, nil)
+	header := NewHeader(genesis)
+	header.BaseFee = *CalcBaseFee(&chain.Gas, &genesis)
+	header.GasUsed = 10240
+	if err := chain.AddBlock(header, newBody(10240)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	child := NewHeader(*header)
+	child.BaseFee = *CalcBaseFee(&chain.Gas, header)
+	if err := chain.AddBlock(child, NewBlock()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if child.BaseFee.Uint64() != 984 {
+		t.Errorf("base fee mismatch: have %v, want 984", &child.BaseFee)
+	}
+
+	// a genesis without a usable gas limit gets the default one
+	chain = NewBlockchain(Header{}, nil, nil)
+	header = NewHeader(*chain.Head())
+	header.GasLimit = CalcGasLimit(&chain.Gas, header.GasLimit)
+	header.BaseFee = *CalcBaseFee(&chain.Gas, chain.Head())
+	if err := chain.AddBlock(header, NewBlock()); err != nil {
+		t.Errorf("child of an empty genesis rejected: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package filters
+
+import (
+	"cxchain223/blockchain"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"errors"
+)
+
+var ErrInvalidRange = errors.New("invalid block range")
+
+// Backend is the part of the chain filters read from.
+type Backend interface {
+	Head() *blockchain.Header
+	GetHeaderByNumber(number uint64) *blockchain.Header
+	GetBody(h hash.Hash) *blockchain.Body
+}
+
+// Criteria selects logs. A log matches if it was emitted by one of
+// Addresses, or any address if there are none, and for every position in
+// Topics its topic at that position is one of the listed hashes. An empty
+// position matches any topic.
+type Criteria struct {
+	Addresses []types.Address
+	Topics    [][]hash.Hash
+}
+
+// FilterLogs returns the logs matching the given addresses and topics in
+// blocks fromBlock to toBlock inclusive. toBlock is capped at the current
+// head. Blocks whose bloom rules out a match are skipped without looking
+// at their receipts.
+func FilterLogs(backend Backend, fromBlock, toBlock uint64, addresses []types.Address, topics [][]hash.Hash) ([]*types.Log, error) {
+	if fromBlock > toBlock {
+		return nil, ErrInvalidRange
+	}
+	criteria := Criteria{Addresses: addresses, Topics: topics}
+	return criteria.scan(backend, fromBlock, min(toBlock, backend.Head().Height)), nil
+}
+
+func (c Criteria) scan(backend Backend, from, to uint64) []*types.Log {
+	var logs []*types.Log
+	for number := from; number <= to; number++ {
+		header := backend.GetHeaderByNumber(number)
+		if header == nil || !c.bloomMatch(header.Bloom) {
+			continue
+		}
+		body := backend.GetBody(header.Hash())
+		if body == nil {
+			continue
+		}
+		for _, receipt := range body.Receiptions {
+			if !c.bloomMatch(receipt.Bloom) {
+				continue
+			}
+			for _, log := range receipt.Logs {
+				if c.match(log) {
+					logs = append(logs, log)
+				}
+			}
+		}
+	}
+	return logs
+}
+
+// bloomMatch reports whether bloom may contain logs matching c.
+func (c Criteria) bloomMatch(bloom types.Bloom) bool {
+	if len(c.Addresses) > 0 {
+		found := false
+		for _, addr := range c.Addresses {
+			if bloom.Test(addr[:]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, sub := range c.Topics {
+		if len(sub) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range sub {
+			if bloom.Test(topic[:]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Criteria) match(log *types.Log) bool {
+	if len(c.Addresses) > 0 && !includes(c.Addresses, log.Address) {
+		return false
+	}
+	if len(c.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, sub := range c.Topics {
+		if len(sub) > 0 && !includes(sub, log.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func includes[T comparable](items []T, item T) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package filters
+
+import (
+	"cxchain223/blockchain"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"testing"
+)
+
+var (
+	addr1  = types.Address{1}
+	addr2  = types.Address{2}
+	topicA = hash.Hash{0xa}
+	topicB = hash.Hash{0xb}
+)
+
+// countingBackend counts how many block bodies are read.
+type countingBackend struct {
+	*blockchain.Blockchain
+	bodies int
+}
+
+func (b *countingBackend) GetBody(h hash.Hash) *blockchain.Body {
+	b.bodies++
+	return b.Blockchain.GetBody(h)
+}
+
+// addBlock appends a block with one receipt holding logs.
+func addBlock(t *testing.T, chain *blockchain.Blockchain, logs ...*types.Log) {
+	header := blockchain.NewHeader(*chain.Head())
+	body := blockchain.NewBlock()
+	for _, log := range logs {
+		log.BlockNumber = header.Height
+	}
+	receipt := types.Receiption{Logs: logs, Bloom: types.LogsBloom(logs)}
+	body.Receiptions = append(body.Receiptions, receipt)
+	header.Bloom = types.CreateBloom(body.Receiptions)
+	if err := chain.AddBlock(header, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestChain(t *testing.T) *countingBackend {
+	chain := blockchain.NewBlockchain(blockchain.Header{}, nil, nil)
+	addBlock(t, chain, &types.Log{Address: addr1, Topics: []hash.Hash{topicA}})
+	addBlock(t, chain)
+	addBlock(t, chain, &types.Log{Address: addr2, Topics: []hash.Hash{topicB, topicA}})
+	addBlock(t, chain, &types.Log{Address: addr1, Topics: []hash.Hash{topicB}}, &types.Log{Address: addr2})
+	return &countingBackend{Blockchain: chain}
+}
+
+func TestFilterLogs(t *testing.T) {
+	for i, test := range []struct {
+		from, to  uint64
+		addresses []types.Address
+		topics    [][]hash.Hash
+		blocks    []uint64 // block numbers of the expected logs
+		bodies    int      // bodies that pass the header bloom
+	}{
+		{0, 100, nil, nil, []uint64{1, 3, 4, 4}, 5},
+		{2, 3, nil, nil, []uint64{3}, 2},
+		{0, 100, []types.Address{addr1}, nil, []uint64{1, 4}, 2},
+		{0, 100, []types.Address{addr1, addr2}, nil, []uint64{1, 3, 4, 4}, 3},
+		{0, 100, nil, [][]hash.Hash{{topicA}}, []uint64{1}, 2},
+		{0, 100, nil, [][]hash.Hash{{}, {topicA}}, []uint64{3}, 2},
+		{0, 100, nil, [][]hash.Hash{{topicA, topicB}}, []uint64{1, 3, 4}, 3},
+		{0, 100, []types.Address{addr2}, [][]hash.Hash{{topicB}}, []uint64{3}, 2},
+		{0, 100, []types.Address{{0xff}}, nil, nil, 0},
+	} {
+		backend := newTestChain(t)
+		logs, err := FilterLogs(backend, test.from, test.to, test.addresses, test.topics)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if len(logs) != len(test.blocks) {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(logs), len(test.blocks))
+			continue
+		}
+		for j, log := range logs {
+			if log.BlockNumber != test.blocks[j] {
+				t.Errorf("test %d: log %d block mismatch: have %d, want %d", i, j, log.BlockNumber, test.blocks[j])
+			}
+		}
+		if backend.bodies != test.bodies {
+			t.Errorf("test %d: bodies read mismatch: have %d, want %d", i, backend.bodies, test.bodies)
+		}
+	}
+
+	if _, err := FilterLogs(newTestChain(t), 3, 2, nil, nil); err != ErrInvalidRange {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidRange)
+	}
+}
+
+func TestLogFilterPoll(t *testing.T) {
+	backend := newTestChain(t)
+	filter := NewLogFilter(backend, Criteria{Addresses: []types.Address{addr1}})
+
+	if logs := filter.Poll(); len(logs) != 0 {
+		t.Fatalf("logs of old blocks returned: %d", len(logs))
+	}
+	addBlock(t, backend.Blockchain, &types.Log{Address: addr1})
+	addBlock(t, backend.Blockchain, &types.Log{Address: addr2}, &types.Log{Address: addr1})
+	logs := filter.Poll()
+	if len(logs) != 2 || logs[0].BlockNumber != 5 || logs[1].BlockNumber != 6 {
+		t.Fatalf("poll mismatch: %v", logs)
+	}
+	if logs := filter.Poll(); len(logs) != 0 {
+		t.Fatalf("logs returned twice: %d", len(logs))
+	}
+}
@@ -0,0 +1,39 @@
+package filters
+
+import (
+	"cxchain223/types"
+	"sync"
+)
+
+// LogFilter is a long lived filter. Every Poll returns the matching logs of
+// the blocks added since the previous one.
+type LogFilter struct {
+	backend  Backend
+	criteria Criteria
+
+	lock sync.Mutex
+	next uint64 // first block not yet scanned
+}
+
+// NewLogFilter creates a filter that starts with the block after the
+// current head.
+func NewLogFilter(backend Backend, criteria Criteria) *LogFilter {
+	return &LogFilter{
+		backend:  backend,
+		criteria: criteria,
+		next:     backend.Head().Height + 1,
+	}
+}
+
+func (f *LogFilter) Poll() []*types.Log {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	head := f.backend.Head().Height
+	if head < f.next {
+		return nil
+	}
+	logs := f.criteria.scan(f.backend, f.next, head)
+	f.next = head + 1
+	return logs
+}
@@ -2,10 +2,13 @@ module cxchain223
 
 go 1.22.1
 
+require (
+	github.com/holiman/uint256 v1.2.4
+	github.com/syndtr/goleveldb v1.0.0
+	golang.org/x/crypto v0.23.0
+)
+
 require (
 	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
-	github.com/holiman/uint256 v1.2.4 // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 )
@@ -7,6 +7,7 @@ import (
 	"cxchain223/txpool"
 	"cxchain223/types"
 	"cxchain223/utils/xtime"
+	"fmt"
 	"time"
 )
 
@@ -22,7 +23,7 @@ type BlockMaker struct {
 	exec   statemachine.IMachine
 
 	config ChainConfig
-	chain  blockchain.Blockchain
+	chain  *blockchain.Blockchain
 
 	nextHeader *blockchain.Header
 	nextBody   *blockchain.Body
@@ -30,47 +31,91 @@ type BlockMaker struct {
 	interupt chan bool
 }
 
-func NewBlockMaker(txpool txpool.TxPool, state statdb.StatDB, exec statemachine.StateMachine) *BlockMaker {
+// NewBlockMaker returns a maker building blocks on top of chain, paying
+// the rewards and fees of its blocks to config.Coinbase.
+func NewBlockMaker(txpool txpool.TxPool, state statdb.StatDB, exec *statemachine.StateMachine, chain *blockchain.Blockchain, config ChainConfig) *BlockMaker {
 	return &BlockMaker{
 		txpool: txpool,
 		state:  state,
 		exec:   exec,
+		config: config,
+		chain:  chain,
+
+		interupt: make(chan bool, 1),
 	}
 }
 
-func (maker BlockMaker) NewBlock() {
+func (maker *BlockMaker) NewBlock() {
 	maker.nextBody = blockchain.NewBlock()
-	maker.nextHeader = blockchain.NewHeader(maker.chain.CurrentHeader)
+	parent := maker.chain.Head()
+	maker.nextHeader = blockchain.NewHeader(*parent)
 	maker.nextHeader.Coinbase = maker.config.Coinbase
+	maker.nextHeader.GasLimit = blockchain.CalcGasLimit(&maker.chain.Gas, parent.GasLimit)
+	maker.nextHeader.BaseFee = *blockchain.CalcBaseFee(&maker.chain.Gas, parent)
+	maker.exec.NewBlock(statemachine.BlockContext{
+		Coinbase: maker.nextHeader.Coinbase,
+		Height:   maker.nextHeader.Height,
+		GasLimit: maker.nextHeader.GasLimit,
+		BaseFee:  maker.nextHeader.BaseFee,
+	})
 }
 
-func (maker BlockMaker) Pack() {
+// Pack fills the block with transactions from the pool until it is full,
+// the duration is up or it is interrupted. It returns an error if a
+// transaction left for the next block cannot be given back to the pool.
+func (maker *BlockMaker) Pack() error {
 	end := time.After(maker.config.Duration)
 	for {
 		select {
 		case <-maker.interupt:
-			break
+			return nil
 		case <-end:
-			break
+			return nil
 		default:
-			maker.pack()
+			if more, err := maker.pack(); !more {
+				return err
+			}
 		}
 	}
 }
 
-func (maker BlockMaker) pack() {
+// pack adds the next transaction of the pool to the block. It returns
+// false once the block has no room for it.
+func (maker *BlockMaker) pack() (bool, error) {
 	tx := maker.txpool.Pop()
-	receiption := maker.exec.Execute1(maker.state, *tx)
+	if tx == nil {
+		return true, nil
+	}
+	if tx.Gas() > maker.nextHeader.GasLimit-maker.nextHeader.GasUsed {
+		// leave it for the next block
+		if err := maker.txpool.GiveBack(tx); err != nil {
+			return false, fmt.Errorf("give back tx %x: %w", tx.Hash(), err)
+		}
+		return false, nil
+	}
+	receiption, err := maker.exec.Execute1(maker.state, *tx)
+	if err != nil {
+		// invalid transactions are dropped from the block
+		maker.txpool.Discard(tx)
+		return true, nil
+	}
+	maker.nextHeader.GasUsed = receiption.CumulativeGasUsed
 	maker.nextBody.Transactions = append(maker.nextBody.Transactions, *tx)
 	maker.nextBody.Receiptions = append(maker.nextBody.Receiptions, *receiption)
+	return true, nil
 }
 
-func (maker BlockMaker) Interupt() {
+func (maker *BlockMaker) Interupt() {
 	maker.interupt <- true
 }
 
-func (maker BlockMaker) Finalize() (*blockchain.Header, *blockchain.Body) {
+func (maker *BlockMaker) Finalize() (*blockchain.Header, *blockchain.Body, error) {
+	// pay the block reward before the block is sealed
+	if err := maker.exec.Finalize(maker.state); err != nil {
+		return nil, nil, err
+	}
 	maker.nextHeader.Timestamp = xtime.Now()
+	maker.nextHeader.Bloom = types.CreateBloom(maker.nextBody.Receiptions)
 	maker.nextHeader.Nonce = 0
 	// TODO
 	// for n := 0; ; n++ {
@@ -80,5 +125,5 @@ func (maker BlockMaker) Finalize() (*blockchain.Header, *blockchain.Body) {
 	// 	}
 	// }
 
-	return maker.nextHeader, maker.nextBody
+	return maker.nextHeader, maker.nextBody, nil
 }
@@ -0,0 +1,122 @@
+package statdb
+
+import (
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+)
+
+// CacheDB buffers all writes on top of a parent StatDB. Nothing reaches
+// the parent until Commit, so a CacheDB can simply be dropped to discard
+// the changes, e.g. when a contract reverts. Without a parent it works as
+// a standalone in-memory StatDB.
+type CacheDB struct {
+	parent StatDB
+
+	accounts map[types.Address]types.Account
+	codes    map[types.Address][]byte
+	storage  map[types.Address]map[hash.Hash]hash.Hash
+	logs     []*types.Log
+}
+
+func NewCacheDB(parent StatDB) *CacheDB {
+	return &CacheDB{
+		parent:   parent,
+		accounts: make(map[types.Address]types.Account),
+		codes:    make(map[types.Address][]byte),
+		storage:  make(map[types.Address]map[hash.Hash]hash.Hash),
+	}
+}
+
+func NewMemoryDB() *CacheDB {
+	return NewCacheDB(nil)
+}
+
+// SetStatRoot drops all buffered writes and moves the parent to root.
+func (db *CacheDB) SetStatRoot(root hash.Hash) {
+	db.accounts = make(map[types.Address]types.Account)
+	db.codes = make(map[types.Address][]byte)
+	db.storage = make(map[types.Address]map[hash.Hash]hash.Hash)
+	db.logs = nil
+	if db.parent != nil {
+		db.parent.SetStatRoot(root)
+	}
+}
+
+func (db *CacheDB) Load(addr types.Address) *types.Account {
+	if account, ok := db.accounts[addr]; ok {
+		return &account
+	}
+	if db.parent != nil {
+		return db.parent.Load(addr)
+	}
+	return nil
+}
+
+func (db *CacheDB) Store(addr types.Address, account types.Account) {
+	db.accounts[addr] = account
+}
+
+func (db *CacheDB) LoadCode(addr types.Address) []byte {
+	if code, ok := db.codes[addr]; ok {
+		return code
+	}
+	if db.parent != nil {
+		return db.parent.LoadCode(addr)
+	}
+	return nil
+}
+
+func (db *CacheDB) StoreCode(addr types.Address, code []byte) {
+	db.codes[addr] = code
+}
+
+func (db *CacheDB) LoadStorage(addr types.Address, key hash.Hash) hash.Hash {
+	if value, ok := db.storage[addr][key]; ok {
+		return value
+	}
+	if db.parent != nil {
+		return db.parent.LoadStorage(addr, key)
+	}
+	return hash.Hash{}
+}
+
+func (db *CacheDB) StoreStorage(addr types.Address, key, value hash.Hash) {
+	slots, ok := db.storage[addr]
+	if !ok {
+		slots = make(map[hash.Hash]hash.Hash)
+		db.storage[addr] = slots
+	}
+	slots[key] = value
+}
+
+// AddLog records a log emitted by a contract.
+func (db *CacheDB) AddLog(log *types.Log) {
+	db.logs = append(db.logs, log)
+}
+
+// Logs returns the logs added to the cache. They are not handed to the
+// parent on Commit, the caller collects them from here.
+func (db *CacheDB) Logs() []*types.Log {
+	return db.logs
+}
+
+// Commit writes all buffered changes to the parent and empties the cache.
+func (db *CacheDB) Commit() {
+	if db.parent == nil {
+		return
+	}
+	for addr, account := range db.accounts {
+		db.parent.Store(addr, account)
+	}
+	for addr, code := range db.codes {
+		db.parent.StoreCode(addr, code)
+	}
+	for addr, slots := range db.storage {
+		for key, value := range slots {
+			db.parent.StoreStorage(addr, key, value)
+		}
+	}
+	db.accounts = make(map[types.Address]types.Account)
+	db.codes = make(map[types.Address][]byte)
+	db.storage = make(map[types.Address]map[hash.Hash]hash.Hash)
+}
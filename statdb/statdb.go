@@ -2,11 +2,16 @@ package statdb
 
 import (
 	"cxchain223/types"
-	"hash"
+	"cxchain223/utils/hash"
 )
 
 type StatDB interface {
 	SetStatRoot(root hash.Hash)
 	Load(addr types.Address) *types.Account
 	Store(addr types.Address, account types.Account)
+
+	LoadCode(addr types.Address) []byte
+	StoreCode(addr types.Address, code []byte)
+	LoadStorage(addr types.Address, key hash.Hash) hash.Hash
+	StoreStorage(addr types.Address, key, value hash.Hash)
 }
@@ -0,0 +1,79 @@
+package statemachine
+
+import (
+	"cxchain223/crypto/sha3"
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/vm"
+)
+
+// call transfers the value of msg to to and runs the code deployed there,
+// if any, or the precompiled contract at that address. Calls to
+// MultisigAddress manage multisig accounts instead. It returns the output
+// of the code and the gas left over.
+func (m *StateMachine) call(state *statdb.CacheDB, msg Message, to types.Address, gas uint64) (ret []byte, gasLeft uint64, err error) {
+	if m.Tracer != nil {
+		m.Tracer.CaptureEnter(CallTypeCall, msg.From, to, msg.Data, gas, &msg.Value)
+		defer func() {
+			m.Tracer.CaptureExit(ret, gas-gasLeft, err)
+		}()
+	}
+	if to == MultisigAddress {
+		return m.callMultisig(state, msg, gas)
+	}
+	m.subBalance(state, msg.From, &msg.Value, BalanceChangeTransfer)
+	m.addBalance(state, to, &msg.Value, BalanceChangeTransfer)
+
+	if p, ok := vm.PrecompiledContracts[to]; ok {
+		return vm.RunPrecompiledContract(p, msg.Data, gas)
+	}
+	code := state.LoadCode(to)
+	if len(code) == 0 {
+		return nil, gas, nil
+	}
+	contract := vm.NewContract(msg.From, to, msg.Value, msg.Data, code, gas)
+	ret, err = m.newInterpreter(state).Run(contract)
+	return ret, contract.Gas, err
+}
+
+// create runs the input of msg as init code for a new contract at addr and
+// deploys the code it returns. It returns the deployed code and the gas
+// left over.
+func (m *StateMachine) create(state *statdb.CacheDB, msg Message, addr types.Address, gas uint64) (ret []byte, gasLeft uint64, err error) {
+	if m.Tracer != nil {
+		m.Tracer.CaptureEnter(CallTypeCreate, msg.From, addr, msg.Data, gas, &msg.Value)
+		defer func() {
+			m.Tracer.CaptureExit(ret, gas-gasLeft, err)
+		}()
+	}
+	if account := state.Load(addr); (account != nil && account.Nonce != 0) || len(state.LoadCode(addr)) > 0 {
+		return nil, 0, vm.ErrContractAddressCollision
+	}
+	m.subBalance(state, msg.From, &msg.Value, BalanceChangeTransfer)
+	m.addBalance(state, addr, &msg.Value, BalanceChangeTransfer)
+
+	contract := vm.NewContract(msg.From, addr, msg.Value, nil, msg.Data, gas)
+	code, err := m.newInterpreter(state).Run(contract)
+	if err != nil {
+		return code, contract.Gas, err
+	}
+	if len(code) > vm.MaxCodeSize {
+		return nil, 0, vm.ErrMaxCodeSizeExceeded
+	}
+	if !contract.UseGas(uint64(len(code)) * vm.CreateDataGas) {
+		return nil, 0, vm.ErrCodeStoreOutOfGas
+	}
+	state.StoreCode(addr, code)
+	account := loadAccount(state, addr)
+	account.CodeHash = sha3.Keccak256(code)
+	state.Store(addr, *account)
+	return code, contract.Gas, nil
+}
+
+func (m *StateMachine) newInterpreter(state *statdb.CacheDB) *vm.Interpreter {
+	in := vm.NewInterpreter(state)
+	if m.Tracer != nil {
+		in.Tracer = m.Tracer
+	}
+	return in
+}
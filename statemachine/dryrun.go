@@ -0,0 +1,104 @@
+package statemachine
+
+import (
+	"cxchain223/statdb"
+	"fmt"
+
+	"github.com/holiman/uint256"
+)
+
+// CallGasCap is the gas given to calls and estimations that do not set a
+// gas limit themselves.
+const CallGasCap uint64 = 50000000
+
+// Call executes msg as if it were included in the block at blockHeight,
+// without changing state. A zero nonce is taken to mean the sender's next
+// nonce and a zero gas limit is raised to CallGasCap. There is no base
+// fee, so callers need not set a gas price. The error is only
+// set if msg could not be included at all, a failing contract is
+// reported in the result. The tracer of m is not called, see TraceCall.
+func (m *StateMachine) Call(state statdb.StatDB, msg Message, blockHeight uint64) (*ExecutionResult, error) {
+	return m.TraceCall(state, msg, blockHeight, nil)
+}
+
+// TraceCall is Call reporting to tracer, which may be nil, instead of the
+// tracer of m.
+func (m *StateMachine) TraceCall(state statdb.StatDB, msg Message, blockHeight uint64, tracer Tracer) (*ExecutionResult, error) {
+	cache := statdb.NewCacheDB(state)
+	if msg.Nonce == 0 {
+		msg.Nonce = loadAccount(cache, msg.From).Nonce + 1
+	}
+	if msg.Gas == 0 {
+		msg.Gas = CallGasCap
+	}
+
+	// run on a copy so the block being built is not disturbed
+	dry := *m
+	dry.Tracer = tracer
+	dry.NewBlock(BlockContext{Coinbase: m.ctx.Coinbase, Height: blockHeight})
+	_, result, err := dry.applyMessage(cache, msg)
+	return result, err
+}
+
+// EstimateGas returns the lowest gas limit msg succeeds with, searching
+// between the intrinsic gas and the gas limit of msg, CallGasCap if it is
+// zero, or whatever less the sender can afford at its fee cap.
+func (m *StateMachine) EstimateGas(state statdb.StatDB, msg Message) (uint64, error) {
+	intrinsic, err := m.Gas.TxIntrinsicGas(msg.Data, msg.AccessList, msg.To == nil, len(msg.Signers))
+	if err != nil {
+		return 0, err
+	}
+	hi := msg.Gas
+	if hi == 0 {
+		hi = CallGasCap
+	}
+	feeCap := &msg.GasPrice
+	if !msg.GasFeeCap.IsZero() {
+		feeCap = &msg.GasFeeCap
+	}
+	if !feeCap.IsZero() {
+		balance := loadAccount(state, msg.From).Amount
+		if balance.Lt(&msg.Value) {
+			return 0, fmt.Errorf("%w: address %x have %v want %v", ErrInsufficientFunds, msg.From, &balance, &msg.Value)
+		}
+		allowance := new(uint256.Int).Sub(&balance, &msg.Value)
+		allowance.Div(allowance, feeCap)
+		if allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+	}
+	if hi < intrinsic {
+		return 0, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, hi, intrinsic)
+	}
+
+	// failed reports whether msg fails with the given gas limit
+	failed := func(gas uint64) (bool, *ExecutionResult, error) {
+		msg.Gas = gas
+		result, err := m.Call(state, msg, m.ctx.Height)
+		if err != nil {
+			return true, nil, err
+		}
+		return result.Failed(), result, nil
+	}
+	fail, result, err := failed(hi)
+	if err != nil {
+		return 0, err
+	}
+	if fail {
+		return 0, fmt.Errorf("gas required exceeds allowance (%d): %w", hi, result.Err)
+	}
+
+	for lo := intrinsic; lo < hi; {
+		mid := lo + (hi-lo)/2
+		fail, _, err := failed(mid)
+		if err != nil {
+			return 0, err
+		}
+		if fail {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
@@ -0,0 +1,98 @@
+package statemachine
+
+import (
+	"bytes"
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"cxchain223/vm"
+	"errors"
+	"testing"
+)
+
+func TestCall(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000), Nonce: 3})
+	identity := types.Address{19: 3}
+	m := newTestMachine()
+
+	// the nonce is filled in and nothing is written
+	msg := Message{From: alice, To: &identity, Data: []byte{1, 2, 3}}
+	result, err := m.Call(state, msg, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Failed() || !bytes.Equal(result.ReturnData, msg.Data) {
+		t.Errorf("result mismatch: %+v", result)
+	}
+	if account := state.Load(alice); account.Nonce != 3 || account.Amount != u256(1000000) {
+		t.Errorf("state modified: %+v", account)
+	}
+	if state.Load(coinbase) != nil {
+		t.Error("coinbase paid by a call")
+	}
+
+	state.StoreCode(contract, counterCode)
+	msg = Message{From: alice, To: &contract, Value: u256(9)}
+	if result, err := m.Call(state, msg, 7); err != nil || !errors.Is(result.Err, vm.ErrExecutionReverted) {
+		t.Errorf("revert mismatch: %v, %v", result, err)
+	}
+	msg.Value = u256(10)
+	if result, err := m.Call(state, msg, 7); err != nil || result.Failed() {
+		t.Errorf("unexpected failure: %v, %v", result, err)
+	}
+	if have := state.LoadStorage(contract, hash.Hash{}); have != (hash.Hash{}) {
+		t.Errorf("storage modified by a call: %x", have)
+	}
+
+	// the message must still be valid
+	msg.Nonce = 1
+	if _, err := m.Call(state, msg, 7); !errors.Is(err, ErrNonceTooLow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNonceTooLow)
+	}
+}
+
+func TestEstimateGas(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	state.StoreCode(contract, counterCode)
+	m := newTestMachine()
+
+	gas, err := m.EstimateGas(state, Message{From: alice, To: &bob, Value: u256(1), GasPrice: u256(1)})
+	if err != nil || gas != DefaultGasSchedule.TxGas {
+		t.Errorf("transfer estimate mismatch: have %d, %v", gas, err)
+	}
+
+	msg := Message{From: alice, To: &contract, Value: u256(10), GasPrice: u256(1)}
+	gas, err = m.EstimateGas(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msg.Gas = gas
+	if result, err := m.Call(state, msg, 0); err != nil || result.Failed() || result.UsedGas != gas {
+		t.Errorf("estimate %d does not pass: %v, %v", gas, result, err)
+	}
+	msg.Gas = gas - 1
+	if result, err := m.Call(state, msg, 0); err != nil || !result.Failed() {
+		t.Errorf("estimate %d is not the lowest: %v, %v", gas, result, err)
+	}
+
+	// a multisig message pays for its signatures
+	wallet := types.Address{0xcc}
+	state.Store(wallet, types.Account{Amount: u256(1000000), Multisig: &types.Multisig{Threshold: 1, Owners: []types.Address{alice}}})
+	msg = Message{From: wallet, To: &bob, Value: u256(1), GasPrice: u256(1), Signers: []types.Address{alice}}
+	if gas, err := m.EstimateGas(state, msg); err != nil || gas != DefaultGasSchedule.TxGas+DefaultGasSchedule.TxSignatureGas {
+		t.Errorf("multisig estimate mismatch: have %d, %v", gas, err)
+	}
+
+	// a reverting call cannot be estimated
+	msg = Message{From: alice, To: &contract, Value: u256(9), GasPrice: u256(1)}
+	if _, err := m.EstimateGas(state, msg); !errors.Is(err, vm.ErrExecutionReverted) {
+		t.Errorf("error mismatch: have %v, want %v", err, vm.ErrExecutionReverted)
+	}
+	// nor one the sender cannot pay for
+	msg = Message{From: alice, To: &contract, Value: u256(10), GasPrice: u256(40)}
+	if _, err := m.EstimateGas(state, msg); !errors.Is(err, vm.ErrOutOfGas) {
+		t.Errorf("error mismatch: have %v, want %v", err, vm.ErrOutOfGas)
+	}
+}
@@ -0,0 +1,59 @@
+package statemachine
+
+import (
+	"cxchain223/types"
+	"errors"
+)
+
+var (
+	// ErrNonceTooLow is returned if the nonce of a transaction is not greater
+	// than the nonce already recorded for the sender.
+	ErrNonceTooLow = errors.New("nonce too low")
+
+	// ErrNonceTooHigh is returned if the nonce of a transaction skips ahead
+	// of the next nonce expected for the sender.
+	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrIntrinsicGas is returned if the gas limit of a transaction does not
+	// cover its intrinsic cost.
+	ErrIntrinsicGas = errors.New("intrinsic gas too low")
+
+	// ErrGasUintOverflow is returned when calculating gas usage overflows.
+	ErrGasUintOverflow = errors.New("gas uint64 overflow")
+
+	// ErrGasLimitReached is returned if the gas limit of a transaction
+	// exceeds the gas left in the block.
+	ErrGasLimitReached = errors.New("gas limit reached")
+
+	// ErrInsufficientFunds is returned if the sender cannot pay for
+	// value + gas * price.
+	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+
+	// ErrBalanceOverflow is returned if the cost of a transaction or any of
+	// the balances it credits does not fit into a uint256.
+	ErrBalanceOverflow = errors.New("balance uint256 overflow")
+
+	// ErrFeeCapTooLow is returned if a transaction cannot pay the base fee
+	// of the block. It is the error of Transaction.EffectiveGasTip, so
+	// either matches with errors.Is.
+	ErrFeeCapTooLow = types.ErrGasFeeCapTooLow
+
+	// ErrTipAboveFeeCap is returned if a transaction offers a tip above its
+	// fee cap.
+	ErrTipAboveFeeCap = errors.New("max priority fee per gas higher than max fee per gas")
+
+	// ErrTrieCreation is returned by Execute for contract creations, which
+	// need code storage a plain trie does not provide.
+	ErrTrieCreation = errors.New("contract creation needs a StatDB")
+
+	// ErrMultisigThreshold is returned if a transaction from a multisig
+	// account is not signed by enough of its owners.
+	ErrMultisigThreshold = errors.New("multisig threshold not reached")
+
+	// ErrNotMultisig is returned for a multisig transaction from an account
+	// without owners.
+	ErrNotMultisig = errors.New("sender is not a multisig account")
+
+	// ErrInvalidMultisig is returned if a multisig owner set is malformed.
+	ErrInvalidMultisig = errors.New("invalid multisig owners")
+)
@@ -0,0 +1,110 @@
+package statemachine
+
+import (
+	"cxchain223/types"
+	"cxchain223/utils/math"
+)
+
+// GasSchedule prices the intrinsic cost of a transaction, charged before
+// anything is executed.
+type GasSchedule struct {
+	TxGas               uint64 // base cost of every transaction
+	TxGasContractCreate uint64 // surcharge for transactions creating a contract
+	TxDataZeroGas       uint64 // per zero byte of input
+	TxDataNonZeroGas    uint64 // per non-zero byte of input
+
+	TxAccessListAddressGas    uint64 // per account in the access list
+	TxAccessListStorageKeyGas uint64 // per storage slot in the access list
+	TxSignatureGas            uint64 // per signature of a multisig transaction
+}
+
+var DefaultGasSchedule = GasSchedule{
+	TxGas:               21000,
+	TxGasContractCreate: 32000,
+	TxDataZeroGas:       4,
+	TxDataNonZeroGas:    16,
+
+	TxAccessListAddressGas:    2400,
+	TxAccessListStorageKeyGas: 1900,
+	TxSignatureGas:            3000,
+}
+
+// SignatureGas computes the gas a multisig transaction pays up front for
+// recovering the given number of signatures.
+func (g GasSchedule) SignatureGas(signatures int) (uint64, error) {
+	gas, overflow := math.SafeMul(uint64(signatures), g.TxSignatureGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	return gas, nil
+}
+
+// TxIntrinsicGas computes all a transaction pays up front, IntrinsicGas and
+// the SignatureGas of the signatures of a multisig transaction, 0 for plain
+// transactions. Pool admission and execution both charge it.
+func (g GasSchedule) TxIntrinsicGas(data []byte, accessList types.AccessList, isContractCreation bool, signatures int) (uint64, error) {
+	gas, err := g.IntrinsicGas(data, accessList, isContractCreation)
+	if err != nil {
+		return 0, err
+	}
+	sigGas, err := g.SignatureGas(signatures)
+	if err != nil {
+		return 0, err
+	}
+	gas, overflow := math.SafeAdd(gas, sigGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	return gas, nil
+}
+
+// IntrinsicGas computes the gas a transaction with the given input and
+// access list has to pay up front.
+func (g GasSchedule) IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation bool) (uint64, error) {
+	gas := g.TxGas
+	if isContractCreation {
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, g.TxGasContractCreate); overflow {
+			return 0, ErrGasUintOverflow
+		}
+	}
+
+	var nz uint64
+	for _, b := range data {
+		if b != 0 {
+			nz++
+		}
+	}
+	z := uint64(len(data)) - nz
+
+	nzGas, overflow := math.SafeMul(nz, g.TxDataNonZeroGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	if gas, overflow = math.SafeAdd(gas, nzGas); overflow {
+		return 0, ErrGasUintOverflow
+	}
+	zGas, overflow := math.SafeMul(z, g.TxDataZeroGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	if gas, overflow = math.SafeAdd(gas, zGas); overflow {
+		return 0, ErrGasUintOverflow
+	}
+
+	addrGas, overflow := math.SafeMul(uint64(len(accessList)), g.TxAccessListAddressGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	if gas, overflow = math.SafeAdd(gas, addrGas); overflow {
+		return 0, ErrGasUintOverflow
+	}
+	keyGas, overflow := math.SafeMul(uint64(accessList.StorageKeys()), g.TxAccessListStorageKeyGas)
+	if overflow {
+		return 0, ErrGasUintOverflow
+	}
+	if gas, overflow = math.SafeAdd(gas, keyGas); overflow {
+		return 0, ErrGasUintOverflow
+	}
+	return gas, nil
+}
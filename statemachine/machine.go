@@ -4,55 +4,391 @@ import (
 	"cxchain223/statdb"
 	"cxchain223/trie"
 	"cxchain223/types"
+	"cxchain223/utils/math"
 	"cxchain223/utils/rlp"
+	"fmt"
+
+	"github.com/holiman/uint256"
 )
 
 type IMachine interface {
+	NewBlock(ctx BlockContext)
 	Execute(state trie.ITrie, tx types.Transaction)
-	Execute1(state statdb.StatDB, tx types.Transaction) *types.Receiption
+	Execute1(state statdb.StatDB, tx types.Transaction) (*types.Receiption, error)
+	Finalize(state statdb.StatDB) error
+}
+
+// BlockContext carries the block level information transactions are
+// executed against.
+type BlockContext struct {
+	Coinbase types.Address
+	Height   uint64
+	GasLimit uint64      // gas all transactions of the block may use, 0 for no limit
+	BaseFee  uint256.Int // least gas price, burnt if RewardConfig.BurnBaseFee is set
 }
 
 type StateMachine struct {
+	ChainID uint64 // chain transactions must be signed for
+	Gas     GasSchedule
+	Rewards RewardConfig
+	Tracer  Tracer // optional
+
+	ctx      BlockContext
+	gasUsed  uint64 // gas used by the current block so far
+	txCount  uint   // transactions included in the current block so far
+	logCount uint   // logs emitted in the current block so far
+	burnt    uint256.Int
+}
+
+// NewStateMachine returns a machine with the default gas schedule. It burns
+// the base fee, where the coinbase used to get the whole gas payment; clear
+// Rewards.BurnBaseFee to pay the coinbase the base fee as well.
+func NewStateMachine() *StateMachine {
+	return &StateMachine{
+		Gas:     DefaultGasSchedule,
+		Rewards: RewardConfig{BurnBaseFee: true},
+	}
 }
 
-func (m StateMachine) Execute(state trie.ITrie, tx types.Transaction) {
-	from := tx.From()
-	to := tx.To
-	value := tx.Value
-	gasUsed := tx.Gas
-	if tx.Gas < 21000 {
-		return
+// NewBlock starts executing a new block, resetting the cumulative gas.
+func (m *StateMachine) NewBlock(ctx BlockContext) {
+	m.ctx = ctx
+	m.gasUsed = 0
+	m.txCount = 0
+	m.logCount = 0
+	m.burnt.Clear()
+}
+
+// Execute1 applies tx to state. Invalid transactions are rejected with an
+// error and leave the state untouched. A transaction whose contract code
+// fails is still charged for its gas and gets a failed receipt.
+func (m *StateMachine) Execute1(state statdb.StatDB, tx types.Transaction) (*types.Receiption, error) {
+	msg, err := TransactionToMessage(m.ChainID, &tx)
+	if err != nil {
+		// rejected before applyMessage, which traces everything else
+		if m.Tracer != nil {
+			m.Tracer.CaptureTxStart(msg)
+			m.Tracer.CaptureTxEnd(nil, err)
+		}
+		return nil, err
+	}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TxHash = tx.Hash()
+	for _, log := range receipt.Logs {
+		log.TxHash = receipt.TxHash
+	}
+	return receipt, nil
+}
+
+func (m *StateMachine) ApplyMessage(state statdb.StatDB, msg Message) (*types.Receiption, error) {
+	receipt, _, err := m.applyMessage(state, msg)
+	return receipt, err
+}
+
+// ExecutionResult is the outcome of running a message's code.
+type ExecutionResult struct {
+	UsedGas    uint64
+	Err        error // error of the contract run, e.g. a revert or out of gas
+	ReturnData []byte
+}
+
+// Failed reports whether the contract run failed.
+func (result *ExecutionResult) Failed() bool {
+	return result.Err != nil
+}
+
+func (m *StateMachine) applyMessage(state statdb.StatDB, msg Message) (receipt *types.Receiption, result *ExecutionResult, err error) {
+	if m.Tracer != nil {
+		m.Tracer.CaptureTxStart(msg)
+		defer func() {
+			m.Tracer.CaptureTxEnd(receipt, err)
+		}()
+	}
+
+	from := loadAccount(state, msg.From)
+	if msg.Nonce <= from.Nonce {
+		return nil, nil, fmt.Errorf("%w: address %x, tx: %d state: %d", ErrNonceTooLow, msg.From, msg.Nonce, from.Nonce)
+	}
+	if msg.Nonce > from.Nonce+1 {
+		return nil, nil, fmt.Errorf("%w: address %x, tx: %d state: %d", ErrNonceTooHigh, msg.From, msg.Nonce, from.Nonce)
+	}
+	if err := CheckMultisig(from, msg.From, msg.Signers); err != nil {
+		return nil, nil, err
+	}
+	contractCreation := msg.To == nil
+	intrinsic, err := m.Gas.TxIntrinsicGas(msg.Data, msg.AccessList, contractCreation, len(msg.Signers))
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.Gas < intrinsic {
+		return nil, nil, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, msg.Gas, intrinsic)
+	}
+	blockGas, overflow := math.SafeAdd(m.gasUsed, msg.Gas)
+	if overflow {
+		return nil, nil, ErrGasUintOverflow
+	}
+	if m.ctx.GasLimit != 0 && blockGas > m.ctx.GasLimit {
+		return nil, nil, fmt.Errorf("%w: have %d, want %d", ErrGasLimitReached, m.ctx.GasLimit-m.gasUsed, msg.Gas)
+	}
+	if err := m.setGasPrice(&msg); err != nil {
+		return nil, nil, err
+	}
+	// the sender must be able to pay the full fee cap
+	maxCost, overflow := new(uint256.Int).MulOverflow(uint256.NewInt(msg.Gas), &msg.GasFeeCap)
+	if overflow {
+		return nil, nil, fmt.Errorf("%w: address %x gas %d fee cap %v", ErrBalanceOverflow, msg.From, msg.Gas, &msg.GasFeeCap)
+	}
+	cost, overflow := new(uint256.Int).AddOverflow(maxCost, &msg.Value)
+	if overflow {
+		return nil, nil, fmt.Errorf("%w: address %x gas cost %v value %v", ErrBalanceOverflow, msg.From, maxCost, &msg.Value)
+	}
+	// bounded by maxCost as the price is at most the fee cap
+	gasCost := new(uint256.Int).Mul(uint256.NewInt(msg.Gas), &msg.GasPrice)
+	if from.Amount.Lt(cost) {
+		return nil, nil, fmt.Errorf("%w: address %x have %v want %v", ErrInsufficientFunds, msg.From, &from.Amount, cost)
+	}
+	var to types.Address
+	if contractCreation {
+		to = types.CreateAddress(msg.From, msg.Nonce)
+	} else {
+		to = *msg.To
+	}
+	if err := checkCredits(state, msg.From, to, &msg.Value, gasCost, m.ctx.Coinbase); err != nil {
+		return nil, nil, err
+	}
+
+	// buy gas and bump the nonce
+	if m.Tracer != nil {
+		m.Tracer.CaptureBalanceChange(msg.From, &from.Amount, new(uint256.Int).Sub(&from.Amount, gasCost), BalanceChangeGasBuy)
+		m.Tracer.CaptureNonceChange(msg.From, from.Nonce, msg.Nonce)
+	}
+	from.Amount.Sub(&from.Amount, gasCost)
+	from.Nonce = msg.Nonce
+	state.Store(msg.From, *from)
+
+	// the transfer and the contract run on a cache, so a failed call
+	// leaves nothing but the gas payment behind
+	cache := statdb.NewCacheDB(state)
+	var (
+		ret     []byte
+		vmerr   error
+		gasLeft = msg.Gas - intrinsic
+	)
+	if contractCreation {
+		ret, gasLeft, vmerr = m.create(cache, msg, to, gasLeft)
+	} else {
+		ret, gasLeft, vmerr = m.call(cache, msg, to, gasLeft)
+	}
+	status := types.ReceiptStatusSuccessful
+	var logs []*types.Log
+	if vmerr != nil {
+		status = types.ReceiptStatusFailed
 	} else {
-		gasUsed = 21000
+		cache.Commit()
+		logs = cache.Logs()
+	}
+	for _, log := range logs {
+		log.BlockNumber = m.ctx.Height
+		log.TxIndex = m.txCount
+		log.Index = m.logCount
+		m.logCount++
+	}
+
+	// refund the unused gas and pay the coinbase for the rest, less what
+	// is burnt. All of it is bounded by gasCost, so nothing can overflow.
+	gasUsed := msg.Gas - gasLeft
+	refund := new(uint256.Int).Mul(uint256.NewInt(gasLeft), &msg.GasPrice)
+	m.addBalance(state, msg.From, refund, BalanceChangeGasRefund)
+	fee := new(uint256.Int).Sub(gasCost, refund)
+	if m.Rewards.BurnBaseFee {
+		burn := new(uint256.Int).Mul(uint256.NewInt(gasUsed), &m.ctx.BaseFee)
+		fee.Sub(fee, burn)
+		m.burnt.Add(&m.burnt, burn)
+	}
+	m.addBalance(state, m.ctx.Coinbase, fee, BalanceChangeGasFee)
+
+	m.gasUsed += gasUsed
+	m.txCount++
+	receipt = &types.Receiption{
+		Status:            status,
+		GasUsed:           gasUsed,
+		CumulativeGasUsed: m.gasUsed,
+		Logs:              logs,
+		Bloom:             types.LogsBloom(logs),
+	}
+	if contractCreation {
+		receipt.ContractAddress = to
+	}
+	return receipt, &ExecutionResult{UsedGas: gasUsed, Err: vmerr, ReturnData: ret}, nil
+}
+
+// setGasPrice works out the gas price msg pays in the current block.
+func (m *StateMachine) setGasPrice(msg *Message) error {
+	if msg.GasFeeCap.IsZero() {
+		msg.GasFeeCap = msg.GasPrice
+		msg.GasTipCap = msg.GasPrice
+	}
+	if msg.GasFeeCap.Lt(&msg.GasTipCap) {
+		return fmt.Errorf("%w: address %x tip %v fee cap %v", ErrTipAboveFeeCap, msg.From, &msg.GasTipCap, &msg.GasFeeCap)
+	}
+	if msg.GasFeeCap.Lt(&m.ctx.BaseFee) {
+		return fmt.Errorf("%w: address %x fee cap %v base fee %v", ErrFeeCapTooLow, msg.From, &msg.GasFeeCap, &m.ctx.BaseFee)
+	}
+	price, overflow := new(uint256.Int).AddOverflow(&m.ctx.BaseFee, &msg.GasTipCap)
+	if overflow || msg.GasFeeCap.Lt(price) {
+		price.Set(&msg.GasFeeCap)
+	}
+	msg.GasPrice = *price
+	return nil
+}
+
+// checkCredits makes sure that crediting the recipient with the value and
+// the coinbase with up to the whole gas cost cannot overflow, so the state
+// is never left half updated.
+func checkCredits(state statdb.StatDB, from, to types.Address, value, gasCost *uint256.Int, coinbase types.Address) error {
+	credits := make(map[types.Address]*uint256.Int)
+	if to != from {
+		credits[to] = new(uint256.Int).Set(value)
+	}
+	if coinbase != from {
+		credit, ok := credits[coinbase]
+		if !ok {
+			credit = new(uint256.Int)
+			credits[coinbase] = credit
+		}
+		if _, overflow := credit.AddOverflow(credit, gasCost); overflow {
+			return fmt.Errorf("%w: address %x", ErrBalanceOverflow, coinbase)
+		}
+	}
+	for addr, credit := range credits {
+		account := loadAccount(state, addr)
+		if _, overflow := new(uint256.Int).AddOverflow(&account.Amount, credit); overflow {
+			return fmt.Errorf("%w: address %x", ErrBalanceOverflow, addr)
+		}
+	}
+	return nil
+}
+
+func loadAccount(state statdb.StatDB, addr types.Address) *types.Account {
+	account := state.Load(addr)
+	if account == nil {
+		return &types.Account{}
+	}
+	return account
+}
+
+func (m *StateMachine) addBalance(state statdb.StatDB, addr types.Address, amount *uint256.Int, reason BalanceChangeReason) {
+	account := loadAccount(state, addr)
+	m.setBalance(state, addr, account, new(uint256.Int).Add(&account.Amount, amount), reason)
+}
+
+func (m *StateMachine) subBalance(state statdb.StatDB, addr types.Address, amount *uint256.Int, reason BalanceChangeReason) {
+	account := loadAccount(state, addr)
+	m.setBalance(state, addr, account, new(uint256.Int).Sub(&account.Amount, amount), reason)
+}
+
+func (m *StateMachine) setBalance(state statdb.StatDB, addr types.Address, account *types.Account, amount *uint256.Int, reason BalanceChangeReason) {
+	if m.Tracer != nil {
+		m.Tracer.CaptureBalanceChange(addr, &account.Amount, amount, reason)
+	}
+	account.Amount = *amount
+	state.Store(addr, *account)
+}
+
+// Execute applies a plain value transfer to a trie holding RLP encoded
+// accounts. It only charges the intrinsic gas. Transactions it cannot
+// apply are skipped, the reason is reported to the tracer.
+func (m *StateMachine) Execute(state trie.ITrie, tx types.Transaction) {
+	msg, err := TransactionToMessage(m.ChainID, &tx)
+	if m.Tracer != nil {
+		m.Tracer.CaptureTxStart(msg)
+	}
+	if err == nil {
+		err = m.execute(state, msg)
+	}
+	if m.Tracer != nil {
+		m.Tracer.CaptureTxEnd(nil, err)
+	}
+}
+
+func (m *StateMachine) execute(state trie.ITrie, msg Message) error {
+	if msg.To == nil {
+		return ErrTrieCreation
+	}
+	from := msg.From
+	to := *msg.To
+	value := msg.Value
+	intrinsic, err := m.Gas.TxIntrinsicGas(msg.Data, msg.AccessList, false, len(msg.Signers))
+	if err != nil {
+		return err
+	}
+	if msg.Gas < intrinsic {
+		return fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, msg.Gas, intrinsic)
+	}
+	gasUsed, overflow := new(uint256.Int).MulOverflow(uint256.NewInt(intrinsic), &msg.GasPrice)
+	if overflow {
+		return fmt.Errorf("%w: address %x gas %d price %v", ErrBalanceOverflow, from, intrinsic, &msg.GasPrice)
+	}
+	cost, overflow := new(uint256.Int).AddOverflow(&value, gasUsed)
+	if overflow {
+		return fmt.Errorf("%w: address %x gas cost %v value %v", ErrBalanceOverflow, from, gasUsed, &value)
 	}
-	gasUsed = gasUsed * tx.GasPrice
-	cost := value + gasUsed
 
 	data, err := state.Load(from[:])
 	if err != nil {
-		return
+		return fmt.Errorf("%w: address %x have 0 want %v", ErrInsufficientFunds, from, cost)
+	}
+	account, err := types.DecodeAccount(data)
+	if err != nil {
+		return err
+	}
+	if err := CheckMultisig(account, msg.From, msg.Signers); err != nil {
+		return err
 	}
-	var account types.Account
-	_ = rlp.DecodeBytes(data, &account)
 
-	if account.Amount < cost {
-		return
+	if account.Amount.Lt(cost) {
+		return fmt.Errorf("%w: address %x have %v want %v", ErrInsufficientFunds, from, &account.Amount, cost)
 	}
 
-	account.Amount = account.Amount - cost
-	data, err = rlp.EncodeToBytes(account)
+	toAccount := &types.Account{}
+	data, err = state.Load(to[:])
+	if err == nil {
+		if toAccount, err = types.DecodeAccount(data); err != nil {
+			return err
+		}
+	}
+	if _, overflow := new(uint256.Int).AddOverflow(&toAccount.Amount, &value); overflow && to != from {
+		return fmt.Errorf("%w: address %x", ErrBalanceOverflow, to)
+	}
 
-	state.Store(from[:], data)
+	balance := new(uint256.Int).Sub(&account.Amount, cost)
+	if m.Tracer != nil {
+		m.Tracer.CaptureBalanceChange(from, &account.Amount, balance, BalanceChangeGasBuy)
+	}
+	account.Amount = *balance
+	if data, err = rlp.EncodeToBytes(account); err != nil {
+		return err
+	}
+	if err = state.Store(from[:], data); err != nil {
+		return err
+	}
 
 	data, err = state.Load(to[:])
-	var toAccount types.Account
 	if err != nil {
-		toAccount = types.Account{}
-	} else {
-		rlp.DecodeBytes(data, &toAccount)
+		toAccount = &types.Account{}
+	} else if toAccount, err = types.DecodeAccount(data); err != nil {
+		return err
 	}
-	toAccount.Amount = toAccount.Amount + value
-	data, err = rlp.EncodeToBytes(toAccount)
-
-	state.Store(to[:], data)
+	balance = new(uint256.Int).Add(&toAccount.Amount, &value)
+	if m.Tracer != nil {
+		m.Tracer.CaptureBalanceChange(to, &toAccount.Amount, balance, BalanceChangeTransfer)
+	}
+	toAccount.Amount = *balance
+	if data, err = rlp.EncodeToBytes(toAccount); err != nil {
+		return err
+	}
+	return state.Store(to[:], data)
 }
@@ -0,0 +1,681 @@
+package statemachine
+
+import (
+	"bytes"
+	"cxchain223/crypto"
+	"cxchain223/crypto/sha3"
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"cxchain223/utils/math"
+	"cxchain223/vm"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// memStat is a StatDB holding plain accounts only, which tests can
+// inspect and compare directly.
+type memStat map[types.Address]types.Account
+
+func (s memStat) SetStatRoot(root hash.Hash) {}
+
+func (s memStat) Load(addr types.Address) *types.Account {
+	account, ok := s[addr]
+	if !ok {
+		return nil
+	}
+	return &account
+}
+
+func (s memStat) Store(addr types.Address, account types.Account) {
+	s[addr] = account
+}
+
+func (s memStat) LoadCode(addr types.Address) []byte { return nil }
+
+func (s memStat) StoreCode(addr types.Address, code []byte) {}
+
+func (s memStat) LoadStorage(addr types.Address, key hash.Hash) hash.Hash { return hash.Hash{} }
+
+func (s memStat) StoreStorage(addr types.Address, key, value hash.Hash) {}
+
+var (
+	alice    = types.Address{1}
+	bob      = types.Address{2}
+	coinbase = types.Address{0xcb}
+)
+
+func newTestMachine() *StateMachine {
+	m := NewStateMachine()
+	m.NewBlock(BlockContext{Coinbase: coinbase, Height: 1})
+	return m
+}
+
+func TestApplyMessage(t *testing.T) {
+	state := memStat{alice: {Amount: u256(1000000)}}
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &bob, Nonce: 1, Value: u256(100), Gas: 30000, GasPrice: u256(2)}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("status mismatch: have %d, want %d", receipt.Status, types.ReceiptStatusSuccessful)
+	}
+	if receipt.GasUsed != DefaultGasSchedule.TxGas || receipt.CumulativeGasUsed != DefaultGasSchedule.TxGas {
+		t.Errorf("gas mismatch: used %d cumulative %d", receipt.GasUsed, receipt.CumulativeGasUsed)
+	}
+	if have, want := state[alice].Amount, u256(1000000-100-2*DefaultGasSchedule.TxGas); have != want {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+	if have := state[alice].Nonce; have != 1 {
+		t.Errorf("sender nonce mismatch: have %d, want 1", have)
+	}
+	if have := state[bob].Amount; have != u256(100) {
+		t.Errorf("recipient balance mismatch: have %v, want 100", have)
+	}
+	if have, want := state[coinbase].Amount, u256(2*DefaultGasSchedule.TxGas); have != want {
+		t.Errorf("coinbase balance mismatch: have %v, want %v", have, want)
+	}
+
+	msg.Nonce = 2
+	receipt, err = m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.CumulativeGasUsed != 2*DefaultGasSchedule.TxGas {
+		t.Errorf("cumulative gas mismatch: have %d, want %d", receipt.CumulativeGasUsed, 2*DefaultGasSchedule.TxGas)
+	}
+}
+
+func TestApplyMessageSelfTransfer(t *testing.T) {
+	state := memStat{alice: {Amount: u256(1000000)}}
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &alice, Nonce: 1, Value: u256(100), Gas: 21000, GasPrice: u256(1)}
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have, want := state[alice].Amount, u256(1000000-21000); have != want {
+		t.Errorf("balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestApplyMessageErrors(t *testing.T) {
+	for i, test := range []struct {
+		account types.Account
+		msg     Message
+		err     error
+	}{
+		{types.Account{Amount: u256(1000000), Nonce: 1}, Message{Nonce: 1, Gas: 21000}, ErrNonceTooLow},
+		{types.Account{Amount: u256(1000000), Nonce: 1}, Message{Nonce: 3, Gas: 21000}, ErrNonceTooHigh},
+		{types.Account{Amount: u256(1000000)}, Message{Nonce: 1, Gas: 20999}, ErrIntrinsicGas},
+		{types.Account{Amount: u256(21000)}, Message{Nonce: 1, Gas: 21000, GasPrice: u256(1), Value: u256(1)}, ErrInsufficientFunds},
+	} {
+		state := memStat{alice: test.account}
+		test.msg.From, test.msg.To = alice, &bob
+		receipt, err := newTestMachine().ApplyMessage(state, test.msg)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if receipt != nil {
+			t.Errorf("test %d: unexpected receipt", i)
+		}
+		if state[alice] != test.account {
+			t.Errorf("test %d: sender modified on failure", i)
+		}
+	}
+}
+
+func TestIntrinsicGas(t *testing.T) {
+	accessList := types.AccessList{{Address: bob, StorageKeys: []hash.Hash{{1}, {2}}}, {Address: contract}}
+	for i, test := range []struct {
+		data       []byte
+		accessList types.AccessList
+		create     bool
+		gas        uint64
+	}{
+		{nil, nil, false, 21000},
+		{nil, nil, true, 53000},
+		{[]byte{0, 0}, nil, false, 21008},
+		{[]byte{1, 0, 2}, nil, false, 21036},
+		{[]byte{1}, nil, true, 53016},
+		{nil, accessList, false, 21000 + 2*2400 + 2*1900},
+	} {
+		gas, err := DefaultGasSchedule.IntrinsicGas(test.data, test.accessList, test.create)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if gas != test.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, test.gas)
+		}
+	}
+
+	schedule := GasSchedule{TxDataNonZeroGas: ^uint64(0)}
+	if _, err := schedule.IntrinsicGas([]byte{1, 1}, nil, false); err != ErrGasUintOverflow {
+		t.Errorf("overflow error mismatch: have %v, want %v", err, ErrGasUintOverflow)
+	}
+	schedule = GasSchedule{TxGas: 1, TxGasContractCreate: ^uint64(0)}
+	if _, err := schedule.IntrinsicGas(nil, nil, true); err != ErrGasUintOverflow {
+		t.Errorf("creation overflow error mismatch: have %v, want %v", err, ErrGasUintOverflow)
+	}
+}
+
+func TestApplyMessageCalldataGas(t *testing.T) {
+	state := memStat{alice: {Amount: u256(1000000)}}
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &bob, Nonce: 1, Gas: 21035, GasPrice: u256(1), Data: []byte{1, 0, 2}}
+	if _, err := m.ApplyMessage(state, msg); !errors.Is(err, ErrIntrinsicGas) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrIntrinsicGas)
+	}
+	msg.Gas = 30000
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.GasUsed != 21036 {
+		t.Errorf("gas used mismatch: have %d, want 21036", receipt.GasUsed)
+	}
+}
+
+func TestApplyMessageOverflow(t *testing.T) {
+	var (
+		max       = *new(uint256.Int).SetAllOne()
+		halfMax   = *new(uint256.Int).Rsh(&max, 1)
+		nearlyMax = *new(uint256.Int).SubUint64(&max, 20999)
+	)
+	for i, test := range []struct {
+		state memStat
+		msg   Message
+		err   error
+	}{
+		// gas * price overflows
+		{memStat{alice: {Amount: max}}, Message{Gas: 21000, GasPrice: halfMax}, ErrBalanceOverflow},
+		// gas * price + value overflows and would otherwise wrap to a tiny cost
+		{memStat{alice: {Amount: max}}, Message{Gas: 21000, GasPrice: u256(1), Value: nearlyMax}, ErrBalanceOverflow},
+		// recipient balance overflows
+		{memStat{alice: {Amount: u256(1000000)}, bob: {Amount: max}}, Message{Gas: 21000, Value: u256(1)}, ErrBalanceOverflow},
+		// coinbase balance overflows
+		{memStat{alice: {Amount: u256(1000000)}, coinbase: {Amount: nearlyMax}}, Message{Gas: 21000, GasPrice: u256(1)}, ErrBalanceOverflow},
+		// sender nonce is exhausted, nothing can follow it
+		{memStat{alice: {Amount: u256(1000000), Nonce: math.MaxUint64}}, Message{Nonce: math.MaxUint64, Gas: 21000}, ErrNonceTooLow},
+		// block gas counter overflows
+		{memStat{alice: {Amount: u256(1000000)}}, Message{Gas: math.MaxUint64}, ErrGasUintOverflow},
+	} {
+		test.msg.From, test.msg.To = alice, &bob
+		if test.msg.Nonce == 0 {
+			test.msg.Nonce = 1
+		}
+		before := copyStat(test.state)
+		m := newTestMachine()
+		m.gasUsed = 1
+		_, err := m.ApplyMessage(test.state, test.msg)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if !equalStat(before, test.state) {
+			t.Errorf("test %d: state modified on failure", i)
+		}
+	}
+}
+
+func FuzzApplyMessage(f *testing.F) {
+	max := new(uint256.Int).SetAllOne().Bytes()
+	f.Add([]byte{0x0f, 0x42, 0x40}, []byte{}, []byte{}, []byte{100}, uint64(21000), []byte{1}, uint8(1), uint8(2))
+	f.Add(max, []byte{}, []byte{}, new(uint256.Int).SubUint64(new(uint256.Int).SetAllOne(), 20999).Bytes(), uint64(21000), []byte{1}, uint8(1), uint8(2))
+	f.Add(max, []byte{}, []byte{}, []byte{}, uint64(math.MaxUint64), max, uint8(1), uint8(2))
+	f.Add([]byte{0x0f, 0x42, 0x40}, max, []byte{}, []byte{1}, uint64(21000), []byte{}, uint8(1), uint8(2))
+	f.Add([]byte{0x0f, 0x42, 0x40}, []byte{}, max, []byte{}, uint64(21000), []byte{1}, uint8(1), uint8(2))
+	f.Add(max, []byte{}, []byte{}, []byte{1}, uint64(21000), []byte{1}, uint8(0), uint8(0))
+	f.Add([]byte{0x0f, 0x42, 0x40}, max, []byte{}, []byte{}, uint64(21000), []byte{1}, uint8(1), uint8(1))
+
+	addrs := []types.Address{alice, bob, coinbase}
+	f.Fuzz(func(t *testing.T, fromBalance, toBalance, coinbaseBalance, value []byte, gas uint64, price []byte, to, cb uint8) {
+		state := memStat{}
+		state[coinbase] = types.Account{Amount: bytesToU256(coinbaseBalance)}
+		state[bob] = types.Account{Amount: bytesToU256(toBalance)}
+		state[alice] = types.Account{Amount: bytesToU256(fromBalance)}
+		before := copyStat(state)
+
+		m := NewStateMachine()
+		m.NewBlock(BlockContext{Coinbase: addrs[int(cb)%len(addrs)]})
+		msg := Message{
+			From:     alice,
+			To:       &addrs[int(to)%len(addrs)],
+			Nonce:    1,
+			Value:    bytesToU256(value),
+			Gas:      gas,
+			GasPrice: bytesToU256(price),
+		}
+		if _, err := m.ApplyMessage(state, msg); err != nil {
+			if !equalStat(before, state) {
+				t.Fatalf("state modified on failure: %v", err)
+			}
+			return
+		}
+		if have, want := supply(state), supply(before); have.Cmp(want) != 0 {
+			t.Fatalf("supply mismatch: have %v, want %v", have, want)
+		}
+	})
+}
+
+func u256(x uint64) uint256.Int {
+	return *uint256.NewInt(x)
+}
+
+func bytesToU256(b []byte) uint256.Int {
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	return *new(uint256.Int).SetBytes(b)
+}
+
+func copyStat(s memStat) memStat {
+	cpy := make(memStat, len(s))
+	for addr, account := range s {
+		cpy[addr] = account
+	}
+	return cpy
+}
+
+func equalStat(a, b memStat) bool {
+	for addr := range b {
+		if _, ok := a[addr]; !ok && b[addr] != (types.Account{}) {
+			return false
+		}
+	}
+	for addr, account := range a {
+		if b[addr] != account {
+			return false
+		}
+	}
+	return true
+}
+
+func supply(s memStat) *big.Int {
+	total := new(big.Int)
+	for _, account := range s {
+		total.Add(total, account.Amount.ToBig())
+	}
+	return total
+}
+
+var contract = types.Address{0xcc}
+
+// counterCode reverts unless called with a value of at least 10, and
+// otherwise increments slot 0 and stores the caller in slot 1.
+var counterCode = []byte{
+	byte(vm.PUSH1), 10, byte(vm.CALLVALUE), byte(vm.LT), // value < 10
+	byte(vm.PUSH1), 21, byte(vm.JUMPI),
+	byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD),
+	byte(vm.PUSH1), 0, byte(vm.SSTORE),
+	byte(vm.CALLER), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+	byte(vm.STOP),
+	byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT),
+}
+
+func TestApplyMessageContract(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	state.StoreCode(contract, counterCode)
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &contract, Nonce: 1, Value: u256(10), Gas: 100000, GasPrice: u256(1)}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("status mismatch: have %d, want %d", receipt.Status, types.ReceiptStatusSuccessful)
+	}
+	if receipt.GasUsed <= DefaultGasSchedule.TxGas+vm.SstoreSetGas {
+		t.Errorf("contract gas not charged: used %d", receipt.GasUsed)
+	}
+	if have := state.LoadStorage(contract, hash.Hash{}); have != hash.BigToHash(big.NewInt(1)) {
+		t.Errorf("counter mismatch: have %x", have)
+	}
+	if have := state.LoadStorage(contract, hash.BigToHash(big.NewInt(1))); have != hash.BytesToHash(alice[:]) {
+		t.Errorf("caller mismatch: have %x", have)
+	}
+	if have := state.Load(contract).Amount; have != u256(10) {
+		t.Errorf("contract balance mismatch: have %v, want 10", &have)
+	}
+	want := u256(1000000 - 10 - receipt.GasUsed)
+	if have := state.Load(alice).Amount; have != want {
+		t.Errorf("sender balance mismatch: have %v, want %v", &have, &want)
+	}
+}
+
+func TestApplyMessageContractRevert(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	state.StoreCode(contract, counterCode)
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &contract, Nonce: 1, Value: u256(9), Gas: 100000, GasPrice: u256(1)}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Fatalf("status mismatch: have %d, want %d", receipt.Status, types.ReceiptStatusFailed)
+	}
+	if receipt.GasUsed >= msg.Gas {
+		t.Errorf("revert should refund unused gas: used %d", receipt.GasUsed)
+	}
+	if have := state.LoadStorage(contract, hash.Hash{}); have != (hash.Hash{}) {
+		t.Errorf("storage modified by reverted call: %x", have)
+	}
+	if account := state.Load(contract); account != nil && !account.Amount.IsZero() {
+		t.Errorf("value transferred by reverted call: %v", &account.Amount)
+	}
+	account := state.Load(alice)
+	if want := u256(1000000 - receipt.GasUsed); account.Amount != want || account.Nonce != 1 {
+		t.Errorf("sender mismatch: have %v nonce %d, want %v nonce 1", &account.Amount, account.Nonce, &want)
+	}
+	if have := state.Load(coinbase).Amount; have != u256(receipt.GasUsed) {
+		t.Errorf("coinbase balance mismatch: have %v, want %d", &have, receipt.GasUsed)
+	}
+}
+
+func TestApplyMessageContractOutOfGas(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	state.StoreCode(contract, counterCode)
+	m := newTestMachine()
+
+	msg := Message{From: alice, To: &contract, Nonce: 1, Value: u256(10), Gas: 30000, GasPrice: u256(1)}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed || receipt.GasUsed != msg.Gas {
+		t.Errorf("receipt mismatch: status %d gas used %d", receipt.Status, receipt.GasUsed)
+	}
+	if have := state.LoadStorage(contract, hash.Hash{}); have != (hash.Hash{}) {
+		t.Errorf("storage modified by failed call: %x", have)
+	}
+}
+
+// runtimeCode sets slot 0 to 1.
+var runtimeCode = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE)}
+
+// initCode sets slot 0 to 42 and deploys runtimeCode.
+var initCode = append(append([]byte{
+	byte(vm.PUSH1), 42, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+	byte(vm.PUSH1) + 4}, runtimeCode...),
+	byte(vm.PUSH1), 0, byte(vm.MSTORE),
+	byte(vm.PUSH1), 5, byte(vm.PUSH1), 27, byte(vm.RETURN),
+)
+
+func TestApplyMessageCreate(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	m := newTestMachine()
+
+	msg := Message{From: alice, Nonce: 1, Value: u256(7), Gas: 200000, GasPrice: u256(1), Data: initCode}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addr := types.CreateAddress(alice, 1)
+	if receipt.Status != types.ReceiptStatusSuccessful || receipt.ContractAddress != addr {
+		t.Fatalf("receipt mismatch: status %d address %x, want %x", receipt.Status, receipt.ContractAddress, addr)
+	}
+	if !bytes.Equal(state.LoadCode(addr), runtimeCode) {
+		t.Errorf("code mismatch: have %x, want %x", state.LoadCode(addr), runtimeCode)
+	}
+	account := state.Load(addr)
+	if account.CodeHash != sha3.Keccak256(runtimeCode) || account.Amount != u256(7) {
+		t.Errorf("contract account mismatch: %+v", account)
+	}
+	if have := state.LoadStorage(addr, hash.Hash{}); have != hash.BigToHash(big.NewInt(42)) {
+		t.Errorf("init code storage mismatch: have %x", have)
+	}
+	intrinsic, _ := DefaultGasSchedule.IntrinsicGas(initCode, nil, true)
+	if receipt.GasUsed < intrinsic+uint64(len(runtimeCode))*vm.CreateDataGas {
+		t.Errorf("creation gas not charged: used %d", receipt.GasUsed)
+	}
+
+	// calling the contract runs the deployed code
+	msg = Message{From: alice, To: &addr, Nonce: 2, Gas: 100000, GasPrice: u256(1)}
+	if receipt, err = m.ApplyMessage(state, msg); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed: %v", err)
+	}
+	if have := state.LoadStorage(addr, hash.Hash{}); have != hash.BigToHash(big.NewInt(1)) {
+		t.Errorf("runtime storage mismatch: have %x", have)
+	}
+}
+
+func TestApplyMessageCreateFailure(t *testing.T) {
+	revert := []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}
+	for i, test := range []struct {
+		code []byte
+		gas  uint64
+	}{
+		{revert, 100000},
+		// not enough gas left to store the code
+		{initCode, 53000 + 20000 + 1000},
+	} {
+		state := statdb.NewMemoryDB()
+		state.Store(alice, types.Account{Amount: u256(1000000)})
+
+		msg := Message{From: alice, Nonce: 1, Value: u256(7), Gas: test.gas, GasPrice: u256(1), Data: test.code}
+		receipt, err := newTestMachine().ApplyMessage(state, msg)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		addr := types.CreateAddress(alice, 1)
+		if receipt.Status != types.ReceiptStatusFailed || receipt.ContractAddress != addr {
+			t.Errorf("test %d: receipt mismatch: status %d address %x", i, receipt.Status, receipt.ContractAddress)
+		}
+		if len(state.LoadCode(addr)) != 0 || state.Load(addr) != nil {
+			t.Errorf("test %d: contract deployed by failed creation", i)
+		}
+		if account := state.Load(alice); account.Nonce != 1 {
+			t.Errorf("test %d: nonce not bumped by failed creation", i)
+		}
+	}
+}
+
+// logCode emits a LOG1 with topic 1 and reverts if the call value is zero.
+var logCode = []byte{
+	byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.LOG1),
+	byte(vm.CALLVALUE), byte(vm.PUSH1), 14, byte(vm.JUMPI),
+	byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT),
+	byte(vm.JUMPDEST),
+}
+
+func TestApplyMessageLogs(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	state.StoreCode(contract, logCode)
+	m := NewStateMachine()
+	m.NewBlock(BlockContext{Coinbase: coinbase, Height: 5})
+	topic := hash.BigToHash(big.NewInt(1))
+
+	var receipts []types.Receiption
+	for i, value := range []uint64{1, 0, 1} {
+		msg := Message{From: alice, To: &contract, Nonce: uint64(i + 1), Value: u256(value), Gas: 100000, GasPrice: u256(1)}
+		receipt, err := m.ApplyMessage(state, msg)
+		if err != nil {
+			t.Fatalf("tx %d: unexpected error: %v", i, err)
+		}
+		receipts = append(receipts, *receipt)
+	}
+
+	if len(receipts[1].Logs) != 0 || receipts[1].Bloom != (types.Bloom{}) {
+		t.Errorf("reverted call kept its logs: %v", receipts[1].Logs)
+	}
+	for i, index := range map[int]uint{0: 0, 2: 1} {
+		logs := receipts[i].Logs
+		if len(logs) != 1 {
+			t.Fatalf("tx %d: log count mismatch: have %d, want 1", i, len(logs))
+		}
+		log := logs[0]
+		if log.Address != contract || len(log.Topics) != 1 || log.Topics[0] != topic {
+			t.Errorf("tx %d: log mismatch: %+v", i, log)
+		}
+		if log.BlockNumber != 5 || log.TxIndex != uint(i) || log.Index != index {
+			t.Errorf("tx %d: log position mismatch: block %d tx %d index %d", i, log.BlockNumber, log.TxIndex, log.Index)
+		}
+		if !receipts[i].Bloom.Test(contract[:]) || !receipts[i].Bloom.Test(topic[:]) {
+			t.Errorf("tx %d: bloom misses the log", i)
+		}
+	}
+	if bloom := types.CreateBloom(receipts); !bloom.Test(topic[:]) {
+		t.Error("block bloom misses the log")
+	}
+}
+
+func TestApplyMessagePrecompile(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	m := newTestMachine()
+	identity := types.Address{19: 3}
+
+	data := []byte{1, 2, 3}
+	msg := Message{From: alice, To: &identity, Nonce: 1, Gas: 100000, GasPrice: u256(1), Data: data}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := DefaultGasSchedule.TxGas + 3*DefaultGasSchedule.TxDataNonZeroGas + vm.IdentityBaseGas + vm.IdentityPerWordGas
+	if receipt.Status != types.ReceiptStatusSuccessful || receipt.GasUsed != want {
+		t.Errorf("receipt mismatch: status %d gas used %d, want %d", receipt.Status, receipt.GasUsed, want)
+	}
+
+	// not enough gas left for the precompile
+	msg.Nonce, msg.Gas = 2, want-1
+	receipt, err = m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed || receipt.GasUsed != msg.Gas {
+		t.Errorf("receipt mismatch: status %d gas used %d", receipt.Status, receipt.GasUsed)
+	}
+}
+
+func TestApplyMessageDynamicFee(t *testing.T) {
+	gas := DefaultGasSchedule.TxGas
+	for i, test := range []struct {
+		feeCap, tipCap uint64
+		price          uint64 // effective gas price
+		err            error
+	}{
+		{10, 2, 9, nil},
+		{10, 5, 10, nil}, // capped
+		{7, 0, 7, nil},
+		{10, 11, 0, ErrTipAboveFeeCap},
+		{6, 0, 0, ErrFeeCapTooLow},
+	} {
+		state := statdb.NewMemoryDB()
+		state.Store(alice, types.Account{Amount: u256(1000000)})
+		m := NewStateMachine()
+		m.NewBlock(BlockContext{Coinbase: coinbase, Height: 1, BaseFee: u256(7)})
+
+		msg := Message{From: alice, To: &bob, Nonce: 1, Gas: gas, GasFeeCap: u256(test.feeCap), GasTipCap: u256(test.tipCap)}
+		_, err := m.ApplyMessage(state, msg)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if err != nil {
+			continue
+		}
+		if have := state.Load(alice).Amount; have != u256(1000000-gas*test.price) {
+			t.Errorf("test %d: sender balance mismatch: have %v, want %d", i, &have, 1000000-gas*test.price)
+		}
+		// the base fee is burnt, the rest tips the coinbase
+		if have := loadAccount(state, coinbase).Amount; have != u256(gas*(test.price-7)) {
+			t.Errorf("test %d: coinbase balance mismatch: have %v, want %d", i, &have, gas*(test.price-7))
+		}
+	}
+}
+
+func TestApplyMessageFeeCapBalance(t *testing.T) {
+	// enough for the price paid, but not for the fee cap
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(21000 * 9)})
+	m := NewStateMachine()
+	m.NewBlock(BlockContext{Coinbase: coinbase, Height: 1, BaseFee: u256(7)})
+
+	msg := Message{From: alice, To: &bob, Nonce: 1, Gas: 21000, GasFeeCap: u256(10), GasTipCap: u256(2)}
+	if _, err := m.ApplyMessage(state, msg); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+}
+
+func TestApplyMessageBlockGasLimit(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	m := NewStateMachine()
+	m.NewBlock(BlockContext{Coinbase: coinbase, Height: 1, GasLimit: 50000})
+
+	msg := Message{From: alice, To: &bob, Nonce: 1, Gas: 30000, GasPrice: u256(1)}
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 21000 used, 29000 left
+	msg.Nonce = 2
+	if _, err := m.ApplyMessage(state, msg); !errors.Is(err, ErrGasLimitReached) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrGasLimitReached)
+	}
+	msg.Gas = 29000
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestExecute1(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := types.PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey))
+	state := statdb.NewMemoryDB()
+	state.Store(sender, types.Account{Amount: u256(1000000)})
+	m := newTestMachine()
+
+	tx := types.NewTx(&types.LegacyTx{To: &bob, Nonce: 1, Value: u256(5), Gas: 21000, GasPrice: u256(1)})
+	if _, err := m.Execute1(state, *tx); !errors.Is(err, types.ErrInvalidSig) {
+		t.Errorf("unsigned tx: error mismatch: have %v, want %v", err, types.ErrInvalidSig)
+	}
+	signed, err := types.SignTx(tx, m.ChainID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := m.Execute1(state, *signed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful || state.Load(bob).Amount != u256(5) {
+		t.Errorf("transfer not applied: status %d", receipt.Status)
+	}
+	if receipt.TxHash != signed.Hash() {
+		t.Errorf("receipt hash mismatch: have %x, want %x", receipt.TxHash, signed.Hash())
+	}
+	if account := state.Load(sender); account.Nonce != 1 || account.Amount != u256(1000000-5-21000) {
+		t.Errorf("sender mismatch: %+v", account)
+	}
+
+	// a dynamic-fee transaction pays for its access list
+	tx = types.NewTx(&types.DynamicFeeTx{
+		To:         &bob,
+		Nonce:      2,
+		GasTipCap:  u256(1),
+		GasFeeCap:  u256(1),
+		Gas:        30000,
+		AccessList: types.AccessList{{Address: bob}},
+	})
+	if signed, err = types.SignTx(tx, m.ChainID, key); err != nil {
+		t.Fatal(err)
+	}
+	if receipt, err = m.Execute1(state, *signed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipt.GasUsed != 21000+2400 {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, 21000+2400)
+	}
+}
@@ -0,0 +1,55 @@
+package statemachine
+
+import (
+	"cxchain223/types"
+
+	"github.com/holiman/uint256"
+)
+
+// Message is a transaction with its sender already resolved. It is the unit
+// of work the state machine executes.
+//
+// A message setting GasFeeCap pays the block base fee plus up to GasTipCap
+// per gas, capped at GasFeeCap, and the machine overwrites GasPrice with
+// the result. Otherwise it pays GasPrice, which must cover the base fee.
+type Message struct {
+	From       types.Address
+	To         *types.Address // nil for contract creation
+	Nonce      uint64
+	Value      uint256.Int
+	Gas        uint64
+	GasPrice   uint256.Int
+	GasFeeCap  uint256.Int
+	GasTipCap  uint256.Int
+	Data       []byte
+	AccessList types.AccessList
+	Signers    []types.Address // owners approving a message from a multisig account
+}
+
+// TransactionToMessage turns tx into a message, recovering its sender for
+// the given chain. If that fails the message is returned with a zero From.
+func TransactionToMessage(chainID uint64, tx *types.Transaction) (Message, error) {
+	msg := Message{
+		To:         tx.To(),
+		Nonce:      tx.Nonce(),
+		Value:      *tx.Value(),
+		Gas:        tx.Gas(),
+		GasPrice:   *tx.GasPrice(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap = *tx.GasFeeCap()
+		msg.GasTipCap = *tx.GasTipCap()
+	}
+	from, err := types.Sender(chainID, tx)
+	if err != nil {
+		return msg, err
+	}
+	msg.From = from
+	if tx.Type() == types.MultisigTxType {
+		// cached by Sender, this does not fail
+		msg.Signers, _ = types.Signers(chainID, tx)
+	}
+	return msg, nil
+}
@@ -0,0 +1,95 @@
+package statemachine
+
+import (
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/rlp"
+	"cxchain223/vm"
+	"fmt"
+)
+
+// MultisigAddress is the system address multisig accounts are managed
+// through, by sending it an RLP encoded types.Multisig. Sent from a plain
+// account it creates a multisig account at CreateAddress(sender, nonce),
+// funded with the value sent, and returns its address. Sent from a
+// multisig account it replaces the owner set of that account, so a change
+// needs the approval of the current owners like any other transaction.
+var MultisigAddress = types.Address{19: 0xfe}
+
+const (
+	MaxMultisigOwners        = types.MaxSignatures // most owners of a multisig account
+	MultisigGas       uint64 = 20000               // per call to MultisigAddress
+)
+
+// CheckMultisig verifies that a transaction from the account at addr is
+// signed by enough owners if it is a multisig account, and is a plain
+// transaction, with nil signers, otherwise.
+func CheckMultisig(account *types.Account, addr types.Address, signers []types.Address) error {
+	if account.Multisig == nil {
+		if signers != nil {
+			return fmt.Errorf("%w: address %x", ErrNotMultisig, addr)
+		}
+		return nil
+	}
+	if !account.Multisig.Approved(signers) {
+		return fmt.Errorf("%w: address %x, signatures: %d threshold: %d", ErrMultisigThreshold, addr, len(signers), account.Multisig.Threshold)
+	}
+	return nil
+}
+
+// validateMultisig checks that owners are distinct and the threshold can
+// be reached.
+func validateMultisig(config *types.Multisig) error {
+	if len(config.Owners) == 0 || len(config.Owners) > MaxMultisigOwners {
+		return fmt.Errorf("%w: %d owners", ErrInvalidMultisig, len(config.Owners))
+	}
+	if config.Threshold == 0 || config.Threshold > uint64(len(config.Owners)) {
+		return fmt.Errorf("%w: threshold %d of %d owners", ErrInvalidMultisig, config.Threshold, len(config.Owners))
+	}
+	seen := make(map[types.Address]bool, len(config.Owners))
+	for _, owner := range config.Owners {
+		if seen[owner] {
+			return fmt.Errorf("%w: duplicate owner %x", ErrInvalidMultisig, owner)
+		}
+		seen[owner] = true
+	}
+	return nil
+}
+
+// callMultisig creates or updates a multisig account as described at
+// MultisigAddress.
+func (m *StateMachine) callMultisig(state *statdb.CacheDB, msg Message, gas uint64) (ret []byte, gasLeft uint64, err error) {
+	if gas < MultisigGas {
+		return nil, 0, vm.ErrOutOfGas
+	}
+	gas -= MultisigGas
+
+	config := new(types.Multisig)
+	if err := rlp.DecodeBytes(msg.Data, config); err != nil {
+		return nil, gas, fmt.Errorf("%w: %v", ErrInvalidMultisig, err)
+	}
+	if err := validateMultisig(config); err != nil {
+		return nil, gas, err
+	}
+
+	from := loadAccount(state, msg.From)
+	if from.Multisig != nil {
+		if !msg.Value.IsZero() {
+			return nil, gas, fmt.Errorf("%w: owner update carries value %v", ErrInvalidMultisig, &msg.Value)
+		}
+		from.Multisig = config
+		state.Store(msg.From, *from)
+		return nil, gas, nil
+	}
+
+	addr := types.CreateAddress(msg.From, msg.Nonce)
+	if account := state.Load(addr); (account != nil && (account.Nonce != 0 || account.Multisig != nil)) || len(state.LoadCode(addr)) > 0 {
+		return nil, gas, vm.ErrContractAddressCollision
+	}
+	m.subBalance(state, msg.From, &msg.Value, BalanceChangeTransfer)
+	m.addBalance(state, addr, &msg.Value, BalanceChangeTransfer)
+	account := loadAccount(state, addr)
+	account.Multisig = config
+	state.Store(addr, *account)
+	return addr[:], gas, nil
+}
@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"crypto/ecdsa"
+	"cxchain223/crypto"
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/rlp"
+	"errors"
+	"testing"
+)
+
+func TestMultisig(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	owners := make([]types.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = types.PubKeyToAddress(crypto.FromECDSAPub(&keys[i].PublicKey))
+	}
+	funder, _ := crypto.GenerateKey()
+	funderAddr := types.PubKeyToAddress(crypto.FromECDSAPub(&funder.PublicKey))
+	state := statdb.NewMemoryDB()
+	state.Store(funderAddr, types.Account{Amount: u256(1000000)})
+	m := newTestMachine()
+
+	// create a 2-of-3 multisig account funded with 500000
+	config, _ := rlp.EncodeToBytes(types.Multisig{Threshold: 2, Owners: owners})
+	tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{To: &MultisigAddress, Nonce: 1, Value: u256(500000), Gas: 100000, Data: config}), m.ChainID, funder)
+	receipt, err := m.Execute1(state, *tx)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("creation failed: %v", err)
+	}
+	wallet := types.CreateAddress(funderAddr, 1)
+	if account := state.Load(wallet); account == nil || account.Multisig == nil || account.Amount != u256(500000) {
+		t.Fatalf("multisig account mismatch: %+v", account)
+	}
+
+	// multisign signs tx from the wallet with the given owners
+	multisign := func(inner *types.MultisigTx, signers ...int) types.Transaction {
+		tx := types.NewTx(inner)
+		for _, i := range signers {
+			tx, _ = types.SignTx(tx, m.ChainID, keys[i])
+		}
+		return *tx
+	}
+	transfer := &types.MultisigTx{Nonce: 1, Gas: 30000, From: wallet, To: &bob, Value: u256(100)}
+	if _, err := m.Execute1(state, multisign(transfer, 0)); !errors.Is(err, ErrMultisigThreshold) {
+		t.Errorf("one signature: error mismatch: have %v, want %v", err, ErrMultisigThreshold)
+	}
+	if _, err := m.Execute1(state, multisign(transfer, 1, 1)); !errors.Is(err, ErrMultisigThreshold) {
+		t.Errorf("repeated signature: error mismatch: have %v, want %v", err, ErrMultisigThreshold)
+	}
+	if receipt, err = m.Execute1(state, multisign(transfer, 0, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Load(bob).Amount != u256(100) {
+		t.Errorf("transfer not applied")
+	}
+	if want := uint64(21000 + 2*3000); receipt.GasUsed != want {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, want)
+	}
+
+	// changing the owners needs the threshold as well
+	config, _ = rlp.EncodeToBytes(types.Multisig{Threshold: 1, Owners: owners[:1]})
+	update := &types.MultisigTx{Nonce: 2, Gas: 100000, From: wallet, To: &MultisigAddress, Data: config}
+	if _, err := m.Execute1(state, multisign(update, 2)); !errors.Is(err, ErrMultisigThreshold) {
+		t.Errorf("update: error mismatch: have %v, want %v", err, ErrMultisigThreshold)
+	}
+	if receipt, err = m.Execute1(state, multisign(update, 1, 2)); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("update failed: %v", err)
+	}
+	if multisig := state.Load(wallet).Multisig; multisig.Threshold != 1 || len(multisig.Owners) != 1 {
+		t.Errorf("owners not updated: %+v", multisig)
+	}
+	transfer.Nonce = 3
+	if _, err := m.Execute1(state, multisign(transfer, 0)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// only multisig accounts take multisig transactions
+	plain := &types.MultisigTx{Nonce: 2, Gas: 30000, From: funderAddr, To: &bob}
+	if _, err := m.Execute1(state, multisign(plain, 0)); !errors.Is(err, ErrNotMultisig) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotMultisig)
+	}
+}
+
+func TestValidateMultisig(t *testing.T) {
+	for i, test := range []struct {
+		config types.Multisig
+		valid  bool
+	}{
+		{types.Multisig{Threshold: 1, Owners: []types.Address{alice}}, true},
+		{types.Multisig{Threshold: 2, Owners: []types.Address{alice, bob}}, true},
+		{types.Multisig{Threshold: 0, Owners: []types.Address{alice}}, false},
+		{types.Multisig{Threshold: 2, Owners: []types.Address{alice}}, false},
+		{types.Multisig{Threshold: 1, Owners: []types.Address{alice, alice}}, false},
+		{types.Multisig{Threshold: 1}, false},
+	} {
+		if err := validateMultisig(&test.config); (err == nil) != test.valid {
+			t.Errorf("test %d: have %v, want valid %v", i, err, test.valid)
+		}
+	}
+}
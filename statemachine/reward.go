@@ -0,0 +1,74 @@
+package statemachine
+
+import (
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"fmt"
+
+	"github.com/holiman/uint256"
+)
+
+// RewardConfig sets how new coins are issued and how fees are shared.
+type RewardConfig struct {
+	BlockReward     uint256.Int // paid to the coinbase of every block
+	HalvingInterval uint64      // blocks between halvings of the reward, 0 to never halve
+
+	// BurnBaseFee burns the base fee part of every gas payment instead of
+	// paying it to the coinbase, which only keeps the rest as a tip.
+	BurnBaseFee bool
+}
+
+// Reward returns the block reward at the given height.
+func (c *RewardConfig) Reward(height uint64) *uint256.Int {
+	reward := new(uint256.Int).Set(&c.BlockReward)
+	if c.HalvingInterval == 0 {
+		return reward
+	}
+	halvings := height / c.HalvingInterval
+	if halvings >= 256 {
+		return reward.Clear()
+	}
+	return reward.Rsh(reward, uint(halvings))
+}
+
+// SupplyAddress keeps the supply ledger in its storage, so that the sum of
+// all balances can be checked against genesis + issued - burnt.
+var SupplyAddress = types.Address{19: 0xff}
+
+var (
+	issuedSlot = hash.Hash{31: 0}
+	burntSlot  = hash.Hash{31: 1}
+)
+
+// Supply returns the coins issued and burnt since genesis.
+func Supply(state statdb.StatDB) (issued, burnt *uint256.Int) {
+	issuedWord := state.LoadStorage(SupplyAddress, issuedSlot)
+	burntWord := state.LoadStorage(SupplyAddress, burntSlot)
+	return new(uint256.Int).SetBytes(issuedWord[:]), new(uint256.Int).SetBytes(burntWord[:])
+}
+
+// Finalize closes the current block. It pays the block reward to the
+// coinbase and adds the coins issued and burnt by the block to the supply
+// ledger.
+func (m *StateMachine) Finalize(state statdb.StatDB) error {
+	reward := m.Rewards.Reward(m.ctx.Height)
+	issued, burnt := Supply(state)
+	if _, overflow := issued.AddOverflow(issued, reward); overflow {
+		return fmt.Errorf("%w: issued supply", ErrBalanceOverflow)
+	}
+	if _, overflow := burnt.AddOverflow(burnt, &m.burnt); overflow {
+		return fmt.Errorf("%w: burnt supply", ErrBalanceOverflow)
+	}
+	coinbase := loadAccount(state, m.ctx.Coinbase)
+	if _, overflow := new(uint256.Int).AddOverflow(&coinbase.Amount, reward); overflow {
+		return fmt.Errorf("%w: address %x", ErrBalanceOverflow, m.ctx.Coinbase)
+	}
+
+	if !reward.IsZero() {
+		m.addBalance(state, m.ctx.Coinbase, reward, BalanceChangeReward)
+	}
+	state.StoreStorage(SupplyAddress, issuedSlot, issued.Bytes32())
+	state.StoreStorage(SupplyAddress, burntSlot, burnt.Bytes32())
+	return nil
+}
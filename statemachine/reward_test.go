@@ -0,0 +1,79 @@
+package statemachine
+
+import (
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"errors"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestReward(t *testing.T) {
+	config := RewardConfig{BlockReward: u256(100), HalvingInterval: 10}
+	for _, test := range []struct {
+		height uint64
+		reward uint64
+	}{
+		{0, 100}, {9, 100}, {10, 50}, {25, 25}, {70, 0}, {10 * 300, 0},
+	} {
+		if have := config.Reward(test.height); have.Uint64() != test.reward {
+			t.Errorf("height %d: reward mismatch: have %v, want %d", test.height, have, test.reward)
+		}
+	}
+	config.HalvingInterval = 0
+	if have := config.Reward(1000); have.Uint64() != 100 {
+		t.Errorf("reward without halving mismatch: have %v", have)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: u256(1000000)})
+	m := newTestMachine()
+	m.Rewards = RewardConfig{BlockReward: u256(100), HalvingInterval: 2, BurnBaseFee: true}
+
+	// balances of everyone touched, minus genesis
+	audit := func() {
+		t.Helper()
+		var total uint256.Int
+		for _, addr := range []types.Address{alice, bob, coinbase} {
+			total.Add(&total, &loadAccount(state, addr).Amount)
+		}
+		issued, burnt := Supply(state)
+		want := new(uint256.Int).Sub(new(uint256.Int).Add(uint256.NewInt(1000000), issued), burnt)
+		if !total.Eq(want) {
+			t.Errorf("supply mismatch: balances %v, genesis + issued - burnt %v", &total, want)
+		}
+	}
+
+	var coinbaseWant uint64
+	for height, reward := range []uint64{100, 100, 50} {
+		m.NewBlock(BlockContext{Coinbase: coinbase, Height: uint64(height), BaseFee: u256(7)})
+		msg := Message{From: alice, To: &bob, Nonce: uint64(height + 1), Value: u256(1), Gas: 21000, GasPrice: u256(10)}
+		if _, err := m.ApplyMessage(state, msg); err != nil {
+			t.Fatalf("block %d: unexpected error: %v", height, err)
+		}
+		if err := m.Finalize(state); err != nil {
+			t.Fatalf("block %d: unexpected error: %v", height, err)
+		}
+		coinbaseWant += 3*21000 + reward
+		if have := state.Load(coinbase).Amount; have != u256(coinbaseWant) {
+			t.Errorf("block %d: coinbase mismatch: have %v, want %d", height, &have, coinbaseWant)
+		}
+		audit()
+	}
+	issued, burnt := Supply(state)
+	if issued.Uint64() != 250 || burnt.Uint64() != 3*7*21000 {
+		t.Errorf("ledger mismatch: issued %v burnt %v", issued, burnt)
+	}
+
+	// a gas price below the base fee is not enough
+	m.NewBlock(BlockContext{Coinbase: coinbase, Height: 3, BaseFee: u256(20)})
+	msg := Message{From: alice, To: &bob, Nonce: 4, Gas: 21000, GasPrice: u256(10)}
+	if _, err := m.ApplyMessage(state, msg); !errors.Is(err, ErrFeeCapTooLow) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrFeeCapTooLow)
+	}
+	m.Finalize(state)
+	audit()
+}
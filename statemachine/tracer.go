@@ -0,0 +1,68 @@
+package statemachine
+
+import (
+	"cxchain223/types"
+	"cxchain223/vm"
+
+	"github.com/holiman/uint256"
+)
+
+// CallType tells calls and contract creations apart in CaptureEnter.
+type CallType string
+
+const (
+	CallTypeCall   CallType = "CALL"
+	CallTypeCreate CallType = "CREATE"
+)
+
+// BalanceChangeReason tells why the machine changed a balance.
+type BalanceChangeReason uint8
+
+const (
+	BalanceChangeUnspecified BalanceChangeReason = iota
+	BalanceChangeTransfer                        // value sent by a transaction
+	BalanceChangeGasBuy                          // gas paid up front by the sender
+	BalanceChangeGasRefund                       // unused gas returned to the sender
+	BalanceChangeGasFee                          // gas fee paid to the coinbase
+	BalanceChangeReward                          // block reward paid to the coinbase
+)
+
+func (r BalanceChangeReason) String() string {
+	switch r {
+	case BalanceChangeTransfer:
+		return "transfer"
+	case BalanceChangeGasBuy:
+		return "gas_buy"
+	case BalanceChangeGasRefund:
+		return "gas_refund"
+	case BalanceChangeGasFee:
+		return "gas_fee"
+	case BalanceChangeReward:
+		return "reward"
+	}
+	return "unspecified"
+}
+
+// Tracer follows the execution of transactions. The machine calls it
+// around every transaction and call and for every balance and nonce
+// change, the vm.Tracer methods see the instructions of contract code.
+//
+// Changes made by a call that fails are reported as they happen, even
+// though they are thrown away afterwards.
+type Tracer interface {
+	vm.Tracer
+
+	// CaptureTxStart is called before msg is validated.
+	CaptureTxStart(msg Message)
+	// CaptureTxEnd is called once msg is done. err is set if msg was
+	// rejected, in which case there is no receipt.
+	CaptureTxEnd(receipt *types.Receiption, err error)
+
+	// CaptureEnter is called when a call or creation starts running.
+	CaptureEnter(typ CallType, from, to types.Address, input []byte, gas uint64, value *uint256.Int)
+	// CaptureExit is called when the innermost running call returns.
+	CaptureExit(output []byte, gasUsed uint64, err error)
+
+	CaptureBalanceChange(addr types.Address, prev, new *uint256.Int, reason BalanceChangeReason)
+	CaptureNonceChange(addr types.Address, prev, new uint64)
+}
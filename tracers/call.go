@@ -0,0 +1,114 @@
+package tracers
+
+import (
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"cxchain223/utils/hexutil"
+	"cxchain223/vm"
+	"encoding/json"
+
+	"github.com/holiman/uint256"
+)
+
+// CallFrame is a call or creation and the calls it made in turn.
+type CallFrame struct {
+	Type    statemachine.CallType `json:"type"`
+	From    hexutil.Bytes         `json:"from"`
+	To      hexutil.Bytes         `json:"to"`
+	Value   hexutil.U256          `json:"value"`
+	Gas     hexutil.Uint64        `json:"gas"`
+	GasUsed hexutil.Uint64        `json:"gasUsed"`
+	Input   hexutil.Bytes         `json:"input"`
+	Output  hexutil.Bytes         `json:"output,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Calls   []*CallFrame          `json:"calls,omitempty"`
+}
+
+// CallTracer builds the tree of calls made by a transaction. The root
+// frame stands for the transaction itself, so its gas includes the
+// intrinsic gas.
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame // frames still running, innermost last
+}
+
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+func (t *CallTracer) CaptureTxStart(msg statemachine.Message) {
+	t.root = &CallFrame{
+		Type:  statemachine.CallTypeCall,
+		From:  msg.From[:],
+		Value: hexutil.U256(msg.Value),
+		Gas:   hexutil.Uint64(msg.Gas),
+		Input: msg.Data,
+	}
+	if msg.To == nil {
+		t.root.Type = statemachine.CallTypeCreate
+	} else {
+		t.root.To = msg.To[:]
+	}
+	t.stack = t.stack[:0]
+}
+
+func (t *CallTracer) CaptureTxEnd(receipt *types.Receiption, err error) {
+	if err != nil {
+		t.root.Error = err.Error()
+		return
+	}
+	t.root.GasUsed = hexutil.Uint64(receipt.GasUsed)
+}
+
+func (t *CallTracer) CaptureEnter(typ statemachine.CallType, from, to types.Address, input []byte, gas uint64, value *uint256.Int) {
+	if len(t.stack) == 0 {
+		// the top level call of the transaction
+		t.root.To = to[:]
+		t.stack = append(t.stack, t.root)
+		return
+	}
+	frame := &CallFrame{
+		Type:  typ,
+		From:  from[:],
+		To:    to[:],
+		Value: hexutil.U256(*value),
+		Gas:   hexutil.Uint64(gas),
+		Input: input,
+	}
+	parent := t.stack[len(t.stack)-1]
+	parent.Calls = append(parent.Calls, frame)
+	t.stack = append(t.stack, frame)
+}
+
+func (t *CallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	frame.Output = output
+	if err != nil {
+		frame.Error = err.Error()
+	}
+	if len(t.stack) > 0 {
+		frame.GasUsed = hexutil.Uint64(gasUsed)
+	}
+}
+
+func (t *CallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, stack *vm.Stack, memory *vm.Memory, contract *vm.Contract) {
+}
+
+func (t *CallTracer) CaptureFault(pc uint64, op vm.OpCode, gas uint64, contract *vm.Contract, err error) {
+}
+
+func (t *CallTracer) CaptureBalanceChange(addr types.Address, prev, new *uint256.Int, reason statemachine.BalanceChangeReason) {
+}
+
+func (t *CallTracer) CaptureNonceChange(addr types.Address, prev, new uint64) {
+}
+
+// Result returns the root frame of the last transaction.
+func (t *CallTracer) Result() *CallFrame {
+	return t.root
+}
+
+func (t *CallTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.root)
+}
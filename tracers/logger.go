@@ -0,0 +1,155 @@
+package tracers
+
+import (
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"cxchain223/utils/hexutil"
+	"cxchain223/vm"
+	"encoding/json"
+
+	"github.com/holiman/uint256"
+)
+
+// StructLog is a single instruction executed by the VM.
+type StructLog struct {
+	Pc      uint64         `json:"pc"`
+	Op      string         `json:"op"`
+	Gas     uint64         `json:"gas"`
+	GasCost uint64         `json:"gasCost"`
+	Depth   int            `json:"depth"`
+	Stack   []hexutil.U256 `json:"stack"`
+	Memory  hexutil.Bytes  `json:"memory,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type BalanceChange struct {
+	Address hexutil.Bytes `json:"address"`
+	Prev    hexutil.U256  `json:"prev"`
+	New     hexutil.U256  `json:"new"`
+	Reason  string        `json:"reason"`
+}
+
+type NonceChange struct {
+	Address hexutil.Bytes  `json:"address"`
+	Prev    hexutil.Uint64 `json:"prev"`
+	New     hexutil.Uint64 `json:"new"`
+}
+
+// StructLoggerResult is the JSON result of a StructLogger.
+type StructLoggerResult struct {
+	Gas            uint64          `json:"gas"`
+	Failed         bool            `json:"failed"`
+	Error          string          `json:"error,omitempty"`
+	ReturnValue    hexutil.Bytes   `json:"returnValue"`
+	StructLogs     []StructLog     `json:"structLogs"`
+	BalanceChanges []BalanceChange `json:"balanceChanges"`
+	NonceChanges   []NonceChange   `json:"nonceChanges"`
+}
+
+// StructLogger records every instruction together with the stack, and
+// optionally the memory, it ran on, as well as all balance and nonce
+// changes of a transaction.
+type StructLogger struct {
+	EnableMemory bool
+
+	depth  int
+	result StructLoggerResult
+}
+
+func NewStructLogger() *StructLogger {
+	return &StructLogger{}
+}
+
+func (l *StructLogger) CaptureTxStart(msg statemachine.Message) {
+	l.depth = 0
+	l.result = StructLoggerResult{
+		StructLogs:     []StructLog{},
+		BalanceChanges: []BalanceChange{},
+		NonceChanges:   []NonceChange{},
+	}
+}
+
+func (l *StructLogger) CaptureTxEnd(receipt *types.Receiption, err error) {
+	if err != nil {
+		l.result.Failed = true
+		l.result.Error = err.Error()
+		return
+	}
+	l.result.Gas = receipt.GasUsed
+	l.result.Failed = receipt.Status == types.ReceiptStatusFailed
+}
+
+func (l *StructLogger) CaptureEnter(typ statemachine.CallType, from, to types.Address, input []byte, gas uint64, value *uint256.Int) {
+	l.depth++
+}
+
+func (l *StructLogger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	l.depth--
+	if l.depth == 0 {
+		l.result.ReturnValue = output
+		if err != nil {
+			l.result.Error = err.Error()
+		}
+	}
+}
+
+func (l *StructLogger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, stack *vm.Stack, memory *vm.Memory, contract *vm.Contract) {
+	log := StructLog{
+		Pc:      pc,
+		Op:      op.String(),
+		Gas:     gas,
+		GasCost: cost,
+		Depth:   l.depth,
+		Stack:   make([]hexutil.U256, stack.Len()),
+	}
+	for i, item := range stack.Data() {
+		log.Stack[i] = hexutil.U256(item)
+	}
+	if l.EnableMemory {
+		log.Memory = append([]byte(nil), memory.Data()...)
+	}
+	l.result.StructLogs = append(l.result.StructLogs, log)
+}
+
+// CaptureFault marks the failing instruction, logging it if it failed
+// before it could start.
+func (l *StructLogger) CaptureFault(pc uint64, op vm.OpCode, gas uint64, contract *vm.Contract, err error) {
+	logs := l.result.StructLogs
+	if n := len(logs); n > 0 && logs[n-1].Pc == pc && logs[n-1].Depth == l.depth {
+		logs[n-1].Error = err.Error()
+		return
+	}
+	l.result.StructLogs = append(logs, StructLog{
+		Pc:    pc,
+		Op:    op.String(),
+		Gas:   gas,
+		Depth: l.depth,
+		Stack: []hexutil.U256{},
+		Error: err.Error(),
+	})
+}
+
+func (l *StructLogger) CaptureBalanceChange(addr types.Address, prev, new *uint256.Int, reason statemachine.BalanceChangeReason) {
+	l.result.BalanceChanges = append(l.result.BalanceChanges, BalanceChange{
+		Address: addr[:],
+		Prev:    hexutil.U256(*prev),
+		New:     hexutil.U256(*new),
+		Reason:  reason.String(),
+	})
+}
+
+func (l *StructLogger) CaptureNonceChange(addr types.Address, prev, new uint64) {
+	l.result.NonceChanges = append(l.result.NonceChanges, NonceChange{
+		Address: addr[:],
+		Prev:    hexutil.Uint64(prev),
+		New:     hexutil.Uint64(new),
+	})
+}
+
+func (l *StructLogger) Result() *StructLoggerResult {
+	return &l.result
+}
+
+func (l *StructLogger) GetResult() (json.RawMessage, error) {
+	return json.Marshal(l.result)
+}
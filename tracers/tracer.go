@@ -0,0 +1,70 @@
+package tracers
+
+import (
+	"cxchain223/blockchain"
+	"cxchain223/statdb"
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"encoding/json"
+	"errors"
+)
+
+var ErrUnknownBlock = errors.New("unknown block")
+
+// Tracer is a state machine tracer that can report what it saw as JSON.
+type Tracer interface {
+	statemachine.Tracer
+	GetResult() (json.RawMessage, error)
+}
+
+// Backend is the part of the chain blocks are replayed from.
+type Backend interface {
+	GetHeaderByHash(h hash.Hash) *blockchain.Header
+	GetBody(h hash.Hash) *blockchain.Body
+}
+
+// TraceBlock replays the block with the given hash on top of the state of
+// its parent and returns the result of a fresh tracer for each of its
+// transactions. state is moved to the parent's root, the replayed changes
+// are never written to it.
+func TraceBlock(backend Backend, state statdb.StatDB, m *statemachine.StateMachine, h hash.Hash, newTracer func() Tracer) ([]json.RawMessage, error) {
+	header := backend.GetHeaderByHash(h)
+	if header == nil {
+		return nil, ErrUnknownBlock
+	}
+	parent := backend.GetHeaderByHash(header.ParentHash)
+	body := backend.GetBody(h)
+	if parent == nil || body == nil {
+		return nil, ErrUnknownBlock
+	}
+	state.SetStatRoot(parent.Root)
+	cache := statdb.NewCacheDB(state)
+
+	replay := *m
+	replay.NewBlock(statemachine.BlockContext{
+		Coinbase: header.Coinbase,
+		Height:   header.Height,
+		GasLimit: header.GasLimit,
+		BaseFee:  header.BaseFee,
+	})
+	txs := make([]*types.Transaction, len(body.Transactions))
+	for i := range body.Transactions {
+		txs[i] = &body.Transactions[i]
+	}
+	types.RecoverSenders(m.ChainID, txs)
+
+	results := make([]json.RawMessage, 0, len(body.Transactions))
+	for _, tx := range body.Transactions {
+		tracer := newTracer()
+		replay.Tracer = tracer
+		// a rejected transaction is recorded by the tracer
+		replay.Execute1(cache, tx)
+		result, err := tracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,199 @@
+package tracers
+
+import (
+	"cxchain223/statdb"
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"cxchain223/vm"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+var (
+	alice    = types.Address{1}
+	contract = types.Address{0xcc}
+	coinbase = types.Address{0xcb}
+)
+
+// storeCode stores the call value in slot 0.
+var storeCode = []byte{byte(vm.CALLVALUE), byte(vm.PUSH1), 0, byte(vm.SSTORE)}
+
+func newTestState() *statdb.CacheDB {
+	state := statdb.NewMemoryDB()
+	state.Store(alice, types.Account{Amount: *uint256.NewInt(1000000)})
+	state.StoreCode(contract, storeCode)
+	return state
+}
+
+func newTestMachine(tracer statemachine.Tracer) *statemachine.StateMachine {
+	m := statemachine.NewStateMachine()
+	m.NewBlock(statemachine.BlockContext{Coinbase: coinbase, Height: 1})
+	m.Tracer = tracer
+	return m
+}
+
+func TestStructLogger(t *testing.T) {
+	logger := NewStructLogger()
+	msg := statemachine.Message{From: alice, To: &contract, Nonce: 1, Value: *uint256.NewInt(5), Gas: 100000, GasPrice: *uint256.NewInt(1)}
+	receipt, err := newTestMachine(logger).ApplyMessage(newTestState(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := logger.Result()
+	if result.Failed || result.Gas != receipt.GasUsed {
+		t.Errorf("result mismatch: failed %v gas %d", result.Failed, result.Gas)
+	}
+	ops := []string{"CALLVALUE", "PUSH1", "SSTORE", "STOP"}
+	if len(result.StructLogs) != len(ops) {
+		t.Fatalf("log count mismatch: have %d, want %d", len(result.StructLogs), len(ops))
+	}
+	var spent uint64
+	for i, log := range result.StructLogs {
+		if log.Op != ops[i] || log.Depth != 1 {
+			t.Errorf("log %d mismatch: %+v", i, log)
+		}
+		spent += log.GasCost
+	}
+	if last := result.StructLogs[2]; last.GasCost != vm.SstoreSetGas || len(last.Stack) != 2 {
+		t.Errorf("SSTORE log mismatch: %+v", last)
+	}
+	if want := receipt.GasUsed - statemachine.DefaultGasSchedule.TxGas; spent != want {
+		t.Errorf("gas cost mismatch: have %d, want %d", spent, want)
+	}
+
+	// gas buy, transfer out and in, refund and fee
+	reasons := []statemachine.BalanceChangeReason{
+		statemachine.BalanceChangeGasBuy,
+		statemachine.BalanceChangeTransfer,
+		statemachine.BalanceChangeTransfer,
+		statemachine.BalanceChangeGasRefund,
+		statemachine.BalanceChangeGasFee,
+	}
+	if len(result.BalanceChanges) != len(reasons) {
+		t.Fatalf("balance change count mismatch: have %d, want %d", len(result.BalanceChanges), len(reasons))
+	}
+	for i, change := range result.BalanceChanges {
+		if change.Reason != reasons[i].String() {
+			t.Errorf("balance change %d reason mismatch: have %s, want %s", i, change.Reason, reasons[i])
+		}
+	}
+	if len(result.NonceChanges) != 1 || result.NonceChanges[0].New != 1 {
+		t.Errorf("nonce changes mismatch: %+v", result.NonceChanges)
+	}
+	if _, err := logger.GetResult(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStructLoggerErrors(t *testing.T) {
+	logger := NewStructLogger()
+	m := newTestMachine(logger)
+	state := newTestState()
+
+	// runs out of gas on SSTORE
+	msg := statemachine.Message{From: alice, To: &contract, Nonce: 1, Value: *uint256.NewInt(5), Gas: 25000, GasPrice: *uint256.NewInt(1)}
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := logger.Result()
+	if !result.Failed || result.Error != vm.ErrOutOfGas.Error() {
+		t.Errorf("result mismatch: failed %v error %q", result.Failed, result.Error)
+	}
+	if n := len(result.StructLogs); n != 3 || result.StructLogs[n-1].Op != "SSTORE" || result.StructLogs[n-1].Error == "" {
+		t.Errorf("fault not logged: %+v", result.StructLogs)
+	}
+
+	// rejected before running
+	msg.Nonce = 1
+	_, err := m.ApplyMessage(state, msg)
+	if !errors.Is(err, statemachine.ErrNonceTooLow) {
+		t.Fatalf("error mismatch: have %v, want %v", err, statemachine.ErrNonceTooLow)
+	}
+	if result := logger.Result(); !result.Failed || result.Error != err.Error() || len(result.StructLogs) != 0 {
+		t.Errorf("result mismatch: %+v", result)
+	}
+}
+
+func TestCallTracer(t *testing.T) {
+	tracer := NewCallTracer()
+	m := newTestMachine(tracer)
+	state := newTestState()
+
+	msg := statemachine.Message{From: alice, To: &contract, Nonce: 1, Value: *uint256.NewInt(5), Gas: 100000, GasPrice: *uint256.NewInt(1)}
+	receipt, err := m.ApplyMessage(state, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := tracer.Result()
+	if frame.Type != statemachine.CallTypeCall || types.Address(frame.To) != contract || uint64(frame.GasUsed) != receipt.GasUsed || frame.Error != "" {
+		t.Errorf("frame mismatch: %+v", frame)
+	}
+
+	// creation frames carry the new address
+	msg = statemachine.Message{From: alice, Nonce: 2, Gas: 100000, GasPrice: *uint256.NewInt(1), Data: []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}}
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame = tracer.Result()
+	if frame.Type != statemachine.CallTypeCreate || types.Address(frame.To) != types.CreateAddress(alice, 2) || frame.Error != vm.ErrExecutionReverted.Error() {
+		t.Errorf("frame mismatch: %+v", frame)
+	}
+
+	data, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded["type"] != "CREATE" || decoded["from"] != "0x0100000000000000000000000000000000000000" {
+		t.Errorf("JSON mismatch: %s", data)
+	}
+
+	// an unsigned transaction is rejected, and still traced
+	unsigned := types.NewTx(&types.LegacyTx{To: &contract, Nonce: 3, Gas: 100000, GasPrice: *uint256.NewInt(1)})
+	if _, err := m.Execute1(state, *unsigned); !errors.Is(err, types.ErrInvalidSig) {
+		t.Fatalf("error mismatch: have %v, want %v", err, types.ErrInvalidSig)
+	}
+	if frame := tracer.Result(); frame == nil || frame.Error != types.ErrInvalidSig.Error() {
+		t.Errorf("frame mismatch: %+v", frame)
+	}
+}
+
+func TestCallNotTraced(t *testing.T) {
+	tracer := NewCallTracer()
+	m := newTestMachine(tracer)
+	state := newTestState()
+
+	msg := statemachine.Message{From: alice, To: &contract, Nonce: 1, Value: *uint256.NewInt(5), Gas: 100000, GasPrice: *uint256.NewInt(1)}
+	if _, err := m.ApplyMessage(state, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := tracer.Result()
+
+	// dry runs leave the tracer of the block alone
+	call := statemachine.Message{From: alice, To: &coinbase, Value: *uint256.NewInt(1)}
+	if _, err := m.Call(state, call, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.EstimateGas(state, call); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tracer.Result() != frame {
+		t.Errorf("block tracer overwritten by a dry run")
+	}
+
+	// unless asked to
+	traced := NewCallTracer()
+	if _, err := m.TraceCall(state, call, 1, traced); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := traced.Result(); result == nil || types.Address(result.To) != coinbase {
+		t.Errorf("frame mismatch: %+v", result)
+	}
+}
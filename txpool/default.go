@@ -1,117 +1,489 @@
 package txpool
 
 import (
+	"bytes"
 	"cxchain223/statdb"
+	"cxchain223/statemachine"
 	"cxchain223/types"
-	"hash"
+	"cxchain223/utils/hash"
+	"fmt"
 	"sort"
+	"sync"
+	"time"
+
+	"github.com/holiman/uint256"
 )
 
+// SortedTxs is a list of transactions of one sender with contiguous
+// nonces, sorted by nonce.
 type SortedTxs interface {
-	GasPrice() uint64
-	Push(tx *types.Transaction)
-	Replace(tx *types.Transaction)
-	Pop() *types.Transaction
-	Nonce() uint64
+	GasPrice() *uint256.Int                // of the first transaction
+	Tip(baseFee *uint256.Int) *uint256.Int // of the first transaction
+	Nonce() uint64                         // of the last transaction
+	Len() int
+	First() *types.Transaction
+	Get(nonce uint64) *types.Transaction                    // nil if nonce is not in the list
+	Push(tx *types.Transaction)                             // appends the next nonce
+	Replace(tx *types.Transaction) (old *types.Transaction) // swaps in tx for the one with its nonce
+	Pop() *types.Transaction                                // removes the first transaction
+	Truncate(n int) (removed []*types.Transaction)          // keeps the first n transactions
 }
 
 type DefaultSortedTxs []*types.Transaction
 
-func (sorted DefaultSortedTxs) GasPrice() uint64 {
-	first := sorted[0]
-	return first.GasPrice
+func (sorted *DefaultSortedTxs) GasPrice() *uint256.Int {
+	return (*sorted)[0].GasPrice()
 }
 
-type pendingTxs []SortedTxs
+// Tip returns what the first transaction pays per gas on top of baseFee,
+// zero if it cannot pay baseFee at all.
+func (sorted *DefaultSortedTxs) Tip(baseFee *uint256.Int) *uint256.Int {
+	tip, err := (*sorted)[0].EffectiveGasTip(baseFee)
+	if err != nil {
+		return new(uint256.Int)
+	}
+	return tip
+}
 
-func (p pendingTxs) Len() int {
-	return len(p)
+func (sorted *DefaultSortedTxs) Nonce() uint64 {
+	return (*sorted)[len(*sorted)-1].Nonce()
 }
 
-func (p pendingTxs) Less(i, j int) bool {
-	return p[i].GasPrice() < p[j].GasPrice()
+func (sorted *DefaultSortedTxs) Len() int {
+	return len(*sorted)
 }
 
-func (p pendingTxs) Swap(i, j int) {
-	p[i], p[j] = p[j], p[i]
+func (sorted *DefaultSortedTxs) First() *types.Transaction {
+	return (*sorted)[0]
 }
 
+func (sorted *DefaultSortedTxs) Get(nonce uint64) *types.Transaction {
+	first := (*sorted)[0].Nonce()
+	if nonce < first || nonce-first >= uint64(len(*sorted)) {
+		return nil
+	}
+	return (*sorted)[nonce-first]
+}
+
+func (sorted *DefaultSortedTxs) Push(tx *types.Transaction) {
+	*sorted = append(*sorted, tx)
+}
+
+func (sorted *DefaultSortedTxs) Replace(tx *types.Transaction) *types.Transaction {
+	i := tx.Nonce() - (*sorted)[0].Nonce()
+	old := (*sorted)[i]
+	(*sorted)[i] = tx
+	return old
+}
+
+func (sorted *DefaultSortedTxs) Pop() *types.Transaction {
+	tx := (*sorted)[0]
+	*sorted = (*sorted)[1:]
+	return tx
+}
+
+func (sorted *DefaultSortedTxs) Truncate(n int) []*types.Transaction {
+	if n >= len(*sorted) {
+		return nil
+	}
+	removed := append([]*types.Transaction(nil), (*sorted)[n:]...)
+	*sorted = (*sorted)[:n]
+	return removed
+}
+
+// DefaultPool keeps the transactions of every sender in two parts. The
+// pending ones continue the nonce of the sender without a gap and can be
+// executed one after the other. The queued ones are further ahead and are
+// promoted to pending once the gap before them fills.
 type DefaultPool struct {
-	Stat statdb.StatDB
+	ChainID     uint64
+	Stat        statdb.StatDB
+	Chain       Chain // read by Reset to re-inject reorganized blocks
+	Gas         statemachine.GasSchedule
+	BaseFee     uint256.Int // base fee of the next block, pending txs are ordered by their tip over it
+	PriceLimit  uint256.Int // least tip a transaction has to offer
+	PriceBump   uint64      // percentage a replacement has to offer over the transaction it replaces
+	MaxDataSize uint64      // largest input a transaction may carry
+
+	AccountSlots int           // most pending transactions of one sender
+	GlobalSlots  int           // most pending transactions of all senders
+	AccountQueue int           // most queued transactions of one sender
+	GlobalQueue  int           // most queued transactions of all senders
+	Lifetime     time.Duration // how long the queued transactions of an idle sender are kept
+
+	lock    sync.Mutex
+	all     map[hash.Hash]*types.Transaction
+	pending map[types.Address]SortedTxs
+	queue   map[types.Address][]*types.Transaction // sorted by nonce
+	popped  map[types.Address][]*types.Transaction // popped but not yet seen in a block, sorted by nonce
+	beats   map[types.Address]time.Time            // last time a tx of the sender was queued
+	metrics Metrics
+}
+
+// Defaults of the pool limits.
+const (
+	DefaultPriceLimit   = 1
+	DefaultMaxDataSize  = 128 * 1024
+	DefaultPriceBump    = 10
+	DefaultAccountSlots = 16
+	DefaultGlobalSlots  = 4096
+	DefaultAccountQueue = 64
+	DefaultGlobalQueue  = 1024
+	DefaultLifetime     = 3 * time.Hour
+)
+
+// NewDefaultPool returns a pool for chainID checking transactions against
+// stat. Reset reads the blocks it moves between from chain, which may be
+// nil if blocks are never reorganized.
+func NewDefaultPool(chainID uint64, stat statdb.StatDB, chain Chain) *DefaultPool {
+	return &DefaultPool{
+		ChainID:     chainID,
+		Stat:        stat,
+		Chain:       chain,
+		Gas:         statemachine.DefaultGasSchedule,
+		PriceLimit:  *uint256.NewInt(DefaultPriceLimit),
+		MaxDataSize: DefaultMaxDataSize,
+		PriceBump:   DefaultPriceBump,
+
+		AccountSlots: DefaultAccountSlots,
+		GlobalSlots:  DefaultGlobalSlots,
+		AccountQueue: DefaultAccountQueue,
+		GlobalQueue:  DefaultGlobalQueue,
+		Lifetime:     DefaultLifetime,
 
-	all      map[hash.Hash]bool
-	txs      pendingTxs
-	pendings map[types.Address][]SortedTxs
-	queue    map[types.Address][]*types.Transaction
+		all:     make(map[hash.Hash]*types.Transaction),
+		pending: make(map[types.Address]SortedTxs),
+		queue:   make(map[types.Address][]*types.Transaction),
+		popped:  make(map[types.Address][]*types.Transaction),
+		beats:   make(map[types.Address]time.Time),
+	}
 }
 
-func (pool DefaultPool) SetStatRoot(root hash.Hash) {
-	pool.Stat.SetStatRoot(root)
+// NewTx adds tx to the pool, or returns why it is not accepted.
+func (pool *DefaultPool) NewTx(tx *types.Transaction) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.add(tx)
 }
 
-func (pool DefaultPool) NewTx(tx *types.Transaction) {
-	account := pool.Stat.Load(tx.From())
-	if account.Nonce >= tx.Nonce {
-		return
+// NewTxs adds a batch of transactions, recovering their senders in
+// parallel first. The errors are those of NewTx, by position.
+func (pool *DefaultPool) NewTxs(txs []*types.Transaction) []error {
+	types.RecoverSenders(pool.ChainID, txs)
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		errs[i] = pool.add(tx)
 	}
+	return errs
+}
 
-	nonce := account.Nonce
-	blks := pool.pendings[tx.From()]
-	if len(blks) > 0 {
-		last := blks[len(blks)-1]
-		nonce = last.Nonce()
+// validateTx checks tx against the limits of the pool and the state of its
+// sender and returns the sender.
+func (pool *DefaultPool) validateTx(tx *types.Transaction) (types.Address, error) {
+	if pool.all[tx.Hash()] != nil {
+		return types.Address{}, fmt.Errorf("%w: %x", ErrAlreadyKnown, tx.Hash())
+	}
+	if size := uint64(len(tx.Data())); size > pool.MaxDataSize {
+		return types.Address{}, fmt.Errorf("%w: have %d, max %d", ErrOversizedData, size, pool.MaxDataSize)
+	}
+	// every signature is recovered, so their number is bounded first
+	if n := tx.NumSignatures(); n > statemachine.MaxMultisigOwners {
+		return types.Address{}, fmt.Errorf("%w: %d signatures, max %d", ErrInvalidSender, n, statemachine.MaxMultisigOwners)
+	}
+	from, err := types.Sender(pool.ChainID, tx)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("%w: %v", ErrInvalidSender, err)
+	}
+	if tip := tx.GasTipCap(); tip.Lt(&pool.PriceLimit) {
+		return types.Address{}, fmt.Errorf("%w: tip %v, minimum %v", ErrUnderpriced, tip, &pool.PriceLimit)
+	}
+	account := pool.Stat.Load(from)
+	if account == nil {
+		account = &types.Account{}
+	}
+	// the sender of a multisig transaction is only claimed, the state
+	// tells whether the signers own it
+	signers, _ := types.Signers(pool.ChainID, tx)
+	if err := statemachine.CheckMultisig(account, from, signers); err != nil {
+		return types.Address{}, err
+	}
+	if tx.Nonce() <= account.Nonce {
+		return types.Address{}, fmt.Errorf("%w: address %x, tx: %d state: %d", ErrNonceTooLow, from, tx.Nonce(), account.Nonce)
+	}
+	var signatures int
+	if tx.Type() == types.MultisigTxType {
+		signatures = tx.NumSignatures()
+	}
+	intrinsic, err := pool.Gas.TxIntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, signatures)
+	if err != nil {
+		return types.Address{}, err
+	}
+	if tx.Gas() < intrinsic {
+		return types.Address{}, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, tx.Gas(), intrinsic)
 	}
-	if tx.Nonce > nonce+1 {
-		pool.addQueueTx(tx)
-	} else if tx.Nonce == nonce+1 {
-		// push
-		pool.pushPendingTx(blks, tx)
+	if cost, overflow := tx.Cost(); overflow || account.Amount.Lt(cost) {
+		return types.Address{}, fmt.Errorf("%w: address %x have %v", ErrInsufficientFunds, from, &account.Amount)
+	}
+	return from, nil
+}
+
+// add inserts tx and then brings the pool back into its limits, which may
+// evict tx right away.
+func (pool *DefaultPool) add(tx *types.Transaction) error {
+	pool.expire()
+	from, err := pool.validateTx(tx)
+	if err != nil {
+		return err
+	}
+	if err := pool.insert(from, tx); err != nil {
+		return err
+	}
+	pool.truncatePending()
+	pool.truncateQueue()
+	if pool.all[tx.Hash()] == nil {
+		return ErrTxPoolOverflow
+	}
+	return nil
+}
+
+func (pool *DefaultPool) insert(from types.Address, tx *types.Transaction) error {
+	nonce := pool.nonce(from)
+	if tx.Nonce() <= nonce {
+		return fmt.Errorf("%w: address %x, tx: %d pool: %d", ErrNonceTooLow, from, tx.Nonce(), nonce)
+	}
+	last := nonce
+	if list := pool.pending[from]; list != nil {
+		last = list.Nonce()
+	}
+	switch {
+	case tx.Nonce() <= last:
+		return pool.replacePendingTx(from, tx)
+	case tx.Nonce() == last+1 && pool.pendingLen(from) < pool.AccountSlots:
+		pool.pushPendingTx(from, tx)
+		pool.promote(from)
+		return nil
+	default:
+		return pool.addQueueTx(from, tx)
+	}
+}
+
+// nonce returns the nonce of from once the transactions popped so far are
+// executed.
+func (pool *DefaultPool) nonce(from types.Address) uint64 {
+	nonce := pool.stateNonce(from)
+	if popped := pool.popped[from]; len(popped) > 0 && popped[len(popped)-1].Nonce() > nonce {
+		return popped[len(popped)-1].Nonce()
+	}
+	return nonce
+}
+
+func (pool *DefaultPool) stateNonce(from types.Address) uint64 {
+	if account := pool.Stat.Load(from); account != nil {
+		return account.Nonce
+	}
+	return 0
+}
+
+// replaces reports whether tx offers enough to replace old, at least
+// PriceBump percent more in both fee cap and tip, and more at all where
+// the percentage rounds down to nothing.
+func (pool *DefaultPool) replaces(old, tx *types.Transaction) bool {
+	bump := uint256.NewInt(100 + pool.PriceBump)
+	hundred := uint256.NewInt(100)
+	for _, price := range [][2]*uint256.Int{
+		{old.GasFeeCap(), tx.GasFeeCap()},
+		{old.GasTipCap(), tx.GasTipCap()},
+	} {
+		min, overflow := new(uint256.Int).MulDivOverflow(price[0], bump, hundred)
+		if overflow || price[1].Lt(min) || !price[0].Lt(price[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pool *DefaultPool) replacePendingTx(from types.Address, tx *types.Transaction) error {
+	list := pool.pending[from]
+	if old := list.Get(tx.Nonce()); !pool.replaces(old, tx) {
+		return fmt.Errorf("%w: fee cap %v, tip %v, bump %d%%", ErrReplaceUnderpriced, old.GasFeeCap(), old.GasTipCap(), pool.PriceBump)
+	}
+	old := list.Replace(tx)
+	delete(pool.all, old.Hash())
+	pool.all[tx.Hash()] = tx
+	return nil
+}
+
+func (pool *DefaultPool) pushPendingTx(from types.Address, tx *types.Transaction) {
+	list := pool.pending[from]
+	if list == nil {
+		list = new(DefaultSortedTxs)
+		pool.pending[from] = list
+	}
+	list.Push(tx)
+	pool.all[tx.Hash()] = tx
+}
+
+// pushFront puts a popped transaction back in front of the pending ones of
+// from.
+func (pool *DefaultPool) pushFront(from types.Address, tx *types.Transaction) {
+	sorted := DefaultSortedTxs{tx}
+	if list := pool.pending[from]; list != nil {
+		sorted = append(sorted, *list.(*DefaultSortedTxs)...)
+	}
+	pool.pending[from] = &sorted
+	pool.all[tx.Hash()] = tx
+}
+
+func (pool *DefaultPool) addQueueTx(from types.Address, tx *types.Transaction) error {
+	list := pool.queue[from]
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].Nonce() >= tx.Nonce()
+	})
+	if i < len(list) && list[i].Nonce() == tx.Nonce() {
+		if old := list[i]; !pool.replaces(old, tx) {
+			return fmt.Errorf("%w: fee cap %v, tip %v, bump %d%%", ErrReplaceUnderpriced, old.GasFeeCap(), old.GasTipCap(), pool.PriceBump)
+		}
+		delete(pool.all, list[i].Hash())
+		list[i] = tx
 	} else {
-		// 替换
-		pool.replacePendingTx(blks, tx)
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = tx
+	}
+	pool.queue[from] = list
+	pool.all[tx.Hash()] = tx
+	pool.beats[from] = time.Now()
+
+	// the account limit drops the transactions furthest ahead
+	if pool.AccountQueue < len(list) {
+		for _, dropped := range list[pool.AccountQueue:] {
+			delete(pool.all, dropped.Hash())
+			pool.metrics.QueuedEvicted++
+		}
+		pool.queue[from] = list[:pool.AccountQueue]
 	}
+	return nil
 }
 
-func (pool DefaultPool) replacePendingTx(blks []SortedTxs, tx *types.Transaction) {
-	for _, blk := range blks {
-		if blk.Nonce() >= tx.Nonce {
-			// replace
-			if blk.GasPrice() <= tx.GasPrice {
-				blk.Replace(tx)
-			}
+// promote moves the queued transactions of from that continue its pending
+// ones over.
+func (pool *DefaultPool) promote(from types.Address) {
+	list := pool.queue[from]
+	i := 0
+	for ; i < len(list) && pool.pendingLen(from) < pool.AccountSlots; i++ {
+		last := pool.nonce(from)
+		if pending := pool.pending[from]; pending != nil {
+			last = pending.Nonce()
+		}
+		if list[i].Nonce() != last+1 {
 			break
 		}
+		pool.pushPendingTx(from, list[i])
+	}
+	if i == len(list) {
+		delete(pool.queue, from)
+		delete(pool.beats, from)
+	} else {
+		pool.queue[from] = list[i:]
 	}
 }
 
-func (pool DefaultPool) pushPendingTx(blks []SortedTxs, tx *types.Transaction) {
-	if len(blks) == 0 {
-		blk := make(DefaultSortedTxs, 1)
-		blk = append(blk, tx)
-		blks = append(blks, blk)
-		pool.pendings[tx.From()] = blks
-		pool.txs = append(pool.txs, blk)
-		sort.Sort(pool.txs)
-	} else {
-		last := blks[len(blks)-1]
-		if last.GasPrice() <= tx.GasPrice {
-			last.Push(tx)
-		} else {
-			blk := make(DefaultSortedTxs, 1)
-			blk = append(blk, tx)
-			blks = append(blks, blk)
-			pool.pendings[tx.From()] = blks
-			pool.txs = append(pool.txs, blk)
-			sort.Sort(pool.txs)
+// Pop removes and returns the executable transaction paying the highest
+// tip over the base fee, nil if there is none. Ties go to the lower sender
+// address, so the order does not depend on map iteration.
+func (pool *DefaultPool) Pop() *types.Transaction {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.expire()
+	var (
+		best     SortedTxs
+		bestTip  *uint256.Int
+		bestFrom types.Address
+	)
+	for from, list := range pool.pending {
+		tip, err := list.First().EffectiveGasTip(&pool.BaseFee)
+		if err != nil {
+			// can't pay the base fee of the next block
+			continue
+		}
+		if best == nil || bestTip.Lt(tip) || (bestTip.Eq(tip) && bytes.Compare(from[:], bestFrom[:]) < 0) {
+			best, bestTip, bestFrom = list, tip, from
 		}
 	}
+	if best == nil {
+		return nil
+	}
+	tx := best.Pop()
+	if best.Len() == 0 {
+		delete(pool.pending, bestFrom)
+	}
+	delete(pool.all, tx.Hash())
+	pool.popped[bestFrom] = append(pool.popped[bestFrom], tx)
+	pool.promote(bestFrom)
+	return tx
+}
+
+// GiveBack puts tx back in front of the pending transactions of its sender.
+// The limits of the pool are not applied, tx was admitted before and is not
+// lost to a full pool or a raised price floor.
+func (pool *DefaultPool) GiveBack(tx *types.Transaction) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	from, err := types.Sender(pool.ChainID, tx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSender, err)
+	}
+	if !pool.unpop(from, tx) {
+		return fmt.Errorf("%w: address %x, tx: %d popped: %d", ErrNotPopped, from, tx.Nonce(), pool.nonce(from))
+	}
+	pool.pushFront(from, tx)
+	return nil
 }
 
-func (pool DefaultPool) addQueueTx(tx *types.Transaction) {
-	list := pool.queue[tx.From()]
-	list = append(list, tx)
-	// sort
+// Discard rolls the nonce of the sender of tx back to before it and moves
+// its pending transactions to the queue, as they no longer continue the
+// nonce. Only the last transaction popped of a sender can be discarded.
+func (pool *DefaultPool) Discard(tx *types.Transaction) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	from, err := types.Sender(pool.ChainID, tx)
+	if err != nil || !pool.unpop(from, tx) {
+		return
+	}
+	pool.demote(from)
+}
+
+// unpop removes tx from the popped transactions of from if it is the last
+// one, and reports whether it was.
+func (pool *DefaultPool) unpop(from types.Address, tx *types.Transaction) bool {
+	popped := pool.popped[from]
+	if len(popped) == 0 || popped[len(popped)-1].Hash() != tx.Hash() {
+		return false
+	}
+	if len(popped) == 1 {
+		delete(pool.popped, from)
+	} else {
+		pool.popped[from] = popped[:len(popped)-1]
+	}
+	return true
 }
 
-func (pool DefaultPool) Pop() *types.Transaction                {}
-func (pool DefaultPool) NotifyTxEvent(txs []*types.Transaction) {}
+// demote moves the pending transactions of from to the queue.
+func (pool *DefaultPool) demote(from types.Address) {
+	list := pool.pending[from]
+	if list == nil {
+		return
+	}
+	delete(pool.pending, from)
+	for _, tx := range list.Truncate(0) {
+		pool.addQueueTx(from, tx)
+	}
+}
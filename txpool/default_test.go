@@ -0,0 +1,298 @@
+package txpool
+
+import (
+	"crypto/ecdsa"
+	"cxchain223/crypto"
+	"cxchain223/statdb"
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"errors"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+type testAccount struct {
+	key  *ecdsa.PrivateKey
+	addr types.Address
+}
+
+func newTestAccount(state *statdb.CacheDB, balance uint64) testAccount {
+	key, _ := crypto.GenerateKey()
+	addr := types.PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey))
+	state.Store(addr, types.Account{Amount: *uint256.NewInt(balance)})
+	return testAccount{key, addr}
+}
+
+func (a testAccount) tx(nonce, price uint64) *types.Transaction {
+	tx := types.NewTx(&types.LegacyTx{To: &types.Address{0xaa}, Nonce: nonce, Gas: 21000, GasPrice: *uint256.NewInt(price)})
+	signed, _ := types.SignTx(tx, 1, a.key)
+	return signed
+}
+
+func newTestPool() (*DefaultPool, *statdb.CacheDB) {
+	state := statdb.NewMemoryDB()
+	return NewDefaultPool(1, state, nil), state
+}
+
+// popAll pops until the pool has no executable transaction left.
+func popAll(pool *DefaultPool) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := pool.Pop(); tx != nil; tx = pool.Pop() {
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func TestPoolPromote(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+
+	pool.NewTx(a.tx(3, 1))
+	pool.NewTx(a.tx(2, 1))
+	if tx := pool.Pop(); tx != nil {
+		t.Fatalf("popped tx %d with a nonce gap", tx.Nonce())
+	}
+	if len(pool.queue[a.addr]) != 2 {
+		t.Fatalf("queued mismatch: have %d, want 2", len(pool.queue[a.addr]))
+	}
+	// filling the gap promotes the queued ones
+	pool.NewTx(a.tx(1, 1))
+	if len(pool.queue[a.addr]) != 0 || pool.pending[a.addr].Len() != 3 {
+		t.Fatalf("not promoted: queued %d", len(pool.queue[a.addr]))
+	}
+	for i, tx := range popAll(pool) {
+		if tx.Nonce() != uint64(i+1) {
+			t.Errorf("pop %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i+1)
+		}
+	}
+	if len(pool.all) != 0 {
+		t.Errorf("popped transactions still known: %d", len(pool.all))
+	}
+
+	// the pool continues after the popped nonces
+	pool.NewTx(a.tx(4, 1))
+	if tx := pool.Pop(); tx == nil || tx.Nonce() != 4 {
+		t.Errorf("next nonce not executable: %v", tx)
+	}
+}
+
+func TestPoolPopOrder(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+	b := newTestAccount(state, 100000000)
+
+	pool.NewTx(a.tx(1, 5))
+	pool.NewTx(a.tx(2, 50))
+	pool.NewTx(b.tx(1, 10))
+	pool.NewTx(b.tx(2, 1))
+
+	// the best price first, but never ahead of a lower nonce of its sender
+	want := []struct {
+		from  types.Address
+		nonce uint64
+	}{{b.addr, 1}, {a.addr, 1}, {a.addr, 2}, {b.addr, 2}}
+	txs := popAll(pool)
+	if len(txs) != len(want) {
+		t.Fatalf("popped %d, want %d", len(txs), len(want))
+	}
+	for i, tx := range txs {
+		from, _ := types.Sender(1, tx)
+		if from != want[i].from || tx.Nonce() != want[i].nonce {
+			t.Errorf("pop %d: have %x/%d, want %x/%d", i, from, tx.Nonce(), want[i].from, want[i].nonce)
+		}
+	}
+}
+
+func TestPoolBaseFee(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+	pool.BaseFee = *uint256.NewInt(10)
+
+	pool.NewTx(a.tx(1, 9))
+	if tx := pool.Pop(); tx != nil {
+		t.Errorf("popped tx below the base fee")
+	}
+	pool.BaseFee.Clear()
+	if tx := pool.Pop(); tx == nil {
+		t.Errorf("tx not executable without a base fee")
+	}
+}
+
+func TestPoolGiveBack(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+	pool.NewTx(a.tx(1, 1))
+	pool.NewTx(a.tx(2, 1))
+
+	// a popped tx that did not fit the block is handed back, even if the
+	// pool would not admit it anymore
+	tx := pool.Pop()
+	pool.PriceLimit = *uint256.NewInt(2)
+	if err := pool.NewTx(tx); !errors.Is(err, ErrUnderpriced) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.GiveBack(a.tx(2, 1)); !errors.Is(err, ErrNotPopped) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotPopped)
+	}
+	if err := pool.GiveBack(tx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, tx := range popAll(pool) {
+		if tx.Nonce() != uint64(i+1) {
+			t.Errorf("pop %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i+1)
+		}
+	}
+}
+
+func TestPoolDiscard(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		pool.NewTx(a.tx(nonce, 1))
+	}
+
+	// the first tx failed, so the others can't be executed
+	pool.Discard(pool.Pop())
+	if tx := pool.Pop(); tx != nil {
+		t.Fatalf("popped nonce %d after a discarded one", tx.Nonce())
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 2 {
+		t.Errorf("stats mismatch: have %d/%d, want 0/2", pending, queued)
+	}
+	// a new tx with the nonce lets them follow again
+	if err := pool.NewTx(a.tx(1, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if txs := popAll(pool); len(txs) != 3 {
+		t.Errorf("popped %d, want 3", len(txs))
+	}
+}
+
+func TestPoolValidation(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000)
+	state.Store(a.addr, types.Account{Amount: *uint256.NewInt(100000), Nonce: 2})
+	pool.PriceLimit = *uint256.NewInt(2)
+	pool.MaxDataSize = 4
+
+	sign := func(inner types.TxData, chainID uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTx(inner), chainID, a.key)
+		return tx
+	}
+	to := &types.Address{0xaa}
+	valid := sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 21000, GasPrice: *uint256.NewInt(2)}, 1)
+	for i, test := range []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{types.NewTx(&types.LegacyTx{To: to, Nonce: 3, Gas: 21000, GasPrice: *uint256.NewInt(2), ChainID: 1}), ErrInvalidSender},
+		{sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 21000, GasPrice: *uint256.NewInt(2)}, 2), ErrInvalidSender},
+		{sign(&types.LegacyTx{To: to, Nonce: 2, Gas: 21000, GasPrice: *uint256.NewInt(2)}, 1), ErrNonceTooLow},
+		{sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 21000, GasPrice: *uint256.NewInt(2), Value: *uint256.NewInt(58001)}, 1), ErrInsufficientFunds},
+		{sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 20999, GasPrice: *uint256.NewInt(2)}, 1), ErrIntrinsicGas},
+		{sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 30000, GasPrice: *uint256.NewInt(2), Data: make([]byte, 5)}, 1), ErrOversizedData},
+		{sign(&types.LegacyTx{To: to, Nonce: 3, Gas: 21000, GasPrice: *uint256.NewInt(1)}, 1), ErrUnderpriced},
+		{sign(&types.DynamicFeeTx{To: to, Nonce: 3, Gas: 21000, GasFeeCap: *uint256.NewInt(4), GasTipCap: *uint256.NewInt(1)}, 1), ErrUnderpriced},
+		{valid, nil},
+		{valid, ErrAlreadyKnown},
+	} {
+		if err := pool.NewTx(test.tx); !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestPoolReplace(t *testing.T) {
+	pool, state := newTestPool()
+	a := newTestAccount(state, 100000000)
+
+	for _, nonce := range []uint64{1, 3} { // pending and queued
+		old := a.tx(nonce, 100)
+		pool.NewTx(old)
+		if err := pool.NewTx(a.tx(nonce, 109)); !errors.Is(err, ErrReplaceUnderpriced) {
+			t.Errorf("nonce %d: error mismatch: have %v, want %v", nonce, err, ErrReplaceUnderpriced)
+		}
+		replacement := a.tx(nonce, 110)
+		if err := pool.NewTx(replacement); err != nil {
+			t.Fatalf("nonce %d: unexpected error: %v", nonce, err)
+		}
+		if pool.all[old.Hash()] != nil || pool.all[replacement.Hash()] == nil {
+			t.Errorf("nonce %d: replaced tx still known", nonce)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 || len(pool.all) != 2 {
+		t.Errorf("stats mismatch: have %d/%d, known %d", pending, queued, len(pool.all))
+	}
+
+	// both the fee cap and the tip have to be bumped
+	dynamic := func(feeCap, tip uint64) *types.Transaction {
+		tx := types.NewTx(&types.DynamicFeeTx{To: &types.Address{0xaa}, Nonce: 2, Gas: 21000, GasFeeCap: *uint256.NewInt(feeCap), GasTipCap: *uint256.NewInt(tip)})
+		signed, _ := types.SignTx(tx, 1, a.key)
+		return signed
+	}
+	pool.NewTx(dynamic(100, 10))
+	if err := pool.NewTx(dynamic(200, 10)); !errors.Is(err, ErrReplaceUnderpriced) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.NewTx(dynamic(110, 11)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// at low prices the bump rounds down to nothing, the price still has
+	// to rise
+	b := newTestAccount(state, 100000000)
+	pool.NewTx(b.tx(1, 5))
+	same, _ := types.SignTx(types.NewTx(&types.LegacyTx{To: &types.Address{0xbb}, Nonce: 1, Gas: 21000, GasPrice: *uint256.NewInt(5)}), 1, b.key)
+	if err := pool.NewTx(same); !errors.Is(err, ErrReplaceUnderpriced) {
+		t.Errorf("equal price: error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.NewTx(b.tx(1, 6)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPoolMultisig(t *testing.T) {
+	pool, state := newTestPool()
+	owners := []testAccount{newTestAccount(state, 0), newTestAccount(state, 0), newTestAccount(state, 0)}
+	wallet, victim := types.Address{0xcc}, newTestAccount(state, 100000000)
+	state.Store(wallet, types.Account{Amount: *uint256.NewInt(100000000), Multisig: &types.Multisig{
+		Threshold: 2,
+		Owners:    []types.Address{owners[0].addr, owners[1].addr, owners[2].addr},
+	}})
+
+	gas := uint64(30000)
+	multisign := func(from types.Address, signers ...testAccount) *types.Transaction {
+		tx := types.NewTx(&types.MultisigTx{From: from, To: &types.Address{0xaa}, Nonce: 1, Gas: gas, GasPrice: *uint256.NewInt(1)})
+		for _, signer := range signers {
+			tx, _ = types.SignTx(tx, 1, signer.key)
+		}
+		return tx
+	}
+	tooMany := make([]testAccount, statemachine.MaxMultisigOwners+1)
+	for i := range tooMany {
+		tooMany[i] = owners[i%len(owners)]
+	}
+	for i, test := range []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		// anyone can claim to send from a plain account
+		{multisign(victim.addr, owners[0], owners[1]), ErrNotMultisig},
+		{multisign(wallet, owners[0]), ErrMultisigThreshold},
+		{multisign(wallet, owners[0], owners[0]), ErrMultisigThreshold},
+		{multisign(wallet, victim, owners[0]), ErrMultisigThreshold},
+		{multisign(wallet, tooMany...), ErrInvalidSender},
+		{multisign(wallet, owners[0], owners[2]), nil},
+	} {
+		if err := pool.NewTx(test.tx); !errors.Is(err, test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+
+	// every signature is paid for up front, as on execution
+	gas = 21000 + 3000
+	if err := pool.NewTx(multisign(wallet, owners[1], owners[2])); !errors.Is(err, ErrIntrinsicGas) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrIntrinsicGas)
+	}
+}
@@ -0,0 +1,56 @@
+package txpool
+
+import (
+	"cxchain223/statemachine"
+	"errors"
+)
+
+var (
+	// ErrAlreadyKnown is returned if the transaction is already in the pool.
+	ErrAlreadyKnown = errors.New("already known")
+
+	// ErrInvalidSender is returned if the sender of the transaction cannot
+	// be recovered for the chain of the pool.
+	ErrInvalidSender = errors.New("invalid sender")
+
+	// ErrNonceTooLow is returned if the nonce of the transaction is already
+	// used by its sender. It is the error of the state machine, so either
+	// matches with errors.Is.
+	ErrNonceTooLow = statemachine.ErrNonceTooLow
+
+	// ErrInsufficientFunds is returned if the sender cannot pay for
+	// value + gas * fee cap.
+	ErrInsufficientFunds = statemachine.ErrInsufficientFunds
+
+	// ErrIntrinsicGas is returned if the gas limit of the transaction does
+	// not cover its intrinsic cost.
+	ErrIntrinsicGas = statemachine.ErrIntrinsicGas
+
+	// ErrNotMultisig is returned if a multisig transaction is sent from a
+	// plain account.
+	ErrNotMultisig = statemachine.ErrNotMultisig
+
+	// ErrMultisigThreshold is returned if a transaction from a multisig
+	// account is not signed by enough of its owners.
+	ErrMultisigThreshold = statemachine.ErrMultisigThreshold
+
+	// ErrOversizedData is returned if the input of the transaction is
+	// larger than the pool accepts.
+	ErrOversizedData = errors.New("oversized data")
+
+	// ErrUnderpriced is returned if the transaction pays less than the
+	// price floor of the pool.
+	ErrUnderpriced = errors.New("transaction underpriced")
+
+	// ErrReplaceUnderpriced is returned if a transaction replacing another
+	// one with the same nonce does not offer the price bump of the pool.
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+
+	// ErrNotPopped is returned if a transaction given back is not the last
+	// one popped of its sender.
+	ErrNotPopped = errors.New("transaction not popped")
+
+	// ErrTxPoolOverflow is returned if the pool is full and the transaction
+	// is cheaper than everything in it.
+	ErrTxPoolOverflow = errors.New("txpool is full")
+)
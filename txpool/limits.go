@@ -0,0 +1,151 @@
+package txpool
+
+import (
+	"bytes"
+	"cxchain223/types"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// Metrics counts the transactions the pool dropped to stay within its
+// limits.
+type Metrics struct {
+	PendingEvicted uint64 // pending txs evicted by cheaper ones
+	QueuedEvicted  uint64 // queued txs evicted by the account or global limit
+	QueuedExpired  uint64 // queued txs of senders idle for longer than Lifetime
+}
+
+// Metrics returns the eviction counts so far.
+func (pool *DefaultPool) Metrics() Metrics {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.metrics
+}
+
+// Stats returns the number of pending and queued transactions.
+func (pool *DefaultPool) Stats() (pending, queued int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.counts()
+}
+
+func (pool *DefaultPool) pendingLen(from types.Address) int {
+	if list := pool.pending[from]; list != nil {
+		return list.Len()
+	}
+	return 0
+}
+
+// tip is what tx offers per gas on top of the base fee, the price
+// eviction is decided by.
+func (pool *DefaultPool) tip(tx *types.Transaction) *uint256.Int {
+	tip, err := tx.EffectiveGasTip(&pool.BaseFee)
+	if err != nil {
+		return new(uint256.Int)
+	}
+	return tip
+}
+
+// cheaper reports whether a of sender fromA is evicted before b of fromB.
+// Ties go against the higher sender address, so eviction does not depend on
+// map iteration.
+func (pool *DefaultPool) cheaper(a *types.Transaction, fromA types.Address, b *types.Transaction, fromB types.Address) bool {
+	tipA, tipB := pool.tip(a), pool.tip(b)
+	if !tipA.Eq(tipB) {
+		return tipA.Lt(tipB)
+	}
+	return bytes.Compare(fromA[:], fromB[:]) > 0
+}
+
+// truncatePending evicts the cheapest of the last pending transactions of
+// each sender until the global limit holds. Only last ones are evicted, so
+// the pending transactions of a sender stay executable.
+func (pool *DefaultPool) truncatePending() {
+	count, _ := pool.counts()
+	for ; count > pool.GlobalSlots; count-- {
+		var (
+			cheapest     *types.Transaction
+			cheapestFrom types.Address
+		)
+		for from, list := range pool.pending {
+			last := list.Get(list.Nonce())
+			if cheapest == nil || pool.cheaper(last, from, cheapest, cheapestFrom) {
+				cheapest, cheapestFrom = last, from
+			}
+		}
+		list := pool.pending[cheapestFrom]
+		list.Truncate(list.Len() - 1)
+		if list.Len() == 0 {
+			delete(pool.pending, cheapestFrom)
+		}
+		delete(pool.all, cheapest.Hash())
+		pool.metrics.PendingEvicted++
+	}
+}
+
+// truncateQueue evicts the cheapest queued transactions until the global
+// limit holds.
+func (pool *DefaultPool) truncateQueue() {
+	_, count := pool.counts()
+	for ; count > pool.GlobalQueue; count-- {
+		var (
+			cheapest     int
+			cheapestFrom types.Address
+			found        bool
+		)
+		for from, list := range pool.queue {
+			for i, tx := range list {
+				if !found || pool.cheaper(tx, from, pool.queue[cheapestFrom][cheapest], cheapestFrom) {
+					cheapest, cheapestFrom, found = i, from, true
+				}
+			}
+		}
+		list := pool.queue[cheapestFrom]
+		delete(pool.all, list[cheapest].Hash())
+		pool.removeQueued(cheapestFrom, cheapest)
+		pool.metrics.QueuedEvicted++
+	}
+}
+
+// expire drops the queued transactions of senders that have not queued
+// anything for longer than Lifetime. It runs whenever the pool is used, on
+// adding, popping and resetting.
+func (pool *DefaultPool) expire() {
+	now := time.Now()
+	for from, list := range pool.queue {
+		if now.Sub(pool.beats[from]) <= pool.Lifetime {
+			continue
+		}
+		for _, tx := range list {
+			delete(pool.all, tx.Hash())
+		}
+		pool.metrics.QueuedExpired += uint64(len(list))
+		delete(pool.queue, from)
+		delete(pool.beats, from)
+	}
+}
+
+// removeQueued removes the i-th queued transaction of from.
+func (pool *DefaultPool) removeQueued(from types.Address, i int) {
+	list := pool.queue[from]
+	list = append(list[:i:i], list[i+1:]...)
+	if len(list) == 0 {
+		delete(pool.queue, from)
+		delete(pool.beats, from)
+	} else {
+		pool.queue[from] = list
+	}
+}
+
+func (pool *DefaultPool) counts() (pending, queued int) {
+	for _, list := range pool.pending {
+		pending += list.Len()
+	}
+	for _, list := range pool.queue {
+		queued += len(list)
+	}
+	return pending, queued
+}
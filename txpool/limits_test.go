@@ -0,0 +1,117 @@
+package txpool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolAccountSlots(t *testing.T) {
+	pool, state := newTestPool()
+	pool.AccountSlots = 2
+	a := newTestAccount(state, 100000000)
+	for nonce := uint64(1); nonce <= 4; nonce++ {
+		if err := pool.NewTx(a.tx(nonce, 1)); err != nil {
+			t.Fatalf("nonce %d: %v", nonce, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 2 {
+		t.Fatalf("stats mismatch: have %d/%d, want 2/2", pending, queued)
+	}
+	// popping makes room for the queued ones
+	if txs := popAll(pool); len(txs) != 4 {
+		t.Errorf("popped %d, want 4", len(txs))
+	}
+}
+
+func TestPoolGlobalSlots(t *testing.T) {
+	pool, state := newTestPool()
+	pool.GlobalSlots = 2
+	a := newTestAccount(state, 100000000)
+	b := newTestAccount(state, 100000000)
+	c := newTestAccount(state, 100000000)
+
+	pool.NewTx(a.tx(1, 3))
+	pool.NewTx(b.tx(1, 2))
+	// the cheapest pending tx makes room for a better one
+	if err := pool.NewTx(c.tx(1, 5)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pool.pending[b.addr] != nil {
+		t.Error("cheapest tx not evicted")
+	}
+	// but a cheaper one is turned away
+	if err := pool.NewTx(b.tx(1, 2)); !errors.Is(err, ErrTxPoolOverflow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrTxPoolOverflow)
+	}
+	if pending, _ := pool.Stats(); pending != 2 || len(pool.all) != 2 {
+		t.Errorf("pending mismatch: have %d, known %d", pending, len(pool.all))
+	}
+	if evicted := pool.Metrics().PendingEvicted; evicted != 2 {
+		t.Errorf("evictions mismatch: have %d, want 2", evicted)
+	}
+}
+
+func TestPoolQueueLimits(t *testing.T) {
+	pool, state := newTestPool()
+	pool.AccountQueue = 2
+	pool.GlobalQueue = 3
+	a := newTestAccount(state, 100000000)
+	b := newTestAccount(state, 100000000)
+
+	pool.NewTx(a.tx(3, 1))
+	pool.NewTx(a.tx(4, 1))
+	// the account limit drops the tx furthest ahead
+	if err := pool.NewTx(a.tx(5, 9)); !errors.Is(err, ErrTxPoolOverflow) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrTxPoolOverflow)
+	}
+	if err := pool.NewTx(a.tx(2, 1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list := pool.queue[a.addr]; len(list) != 2 || list[0].Nonce() != 2 || list[1].Nonce() != 3 {
+		t.Fatalf("queue mismatch")
+	}
+	// the global limit drops the cheapest
+	pool.NewTx(b.tx(2, 5))
+	pool.NewTx(b.tx(3, 5))
+	if _, queued := pool.Stats(); queued != 3 {
+		t.Errorf("queued mismatch: have %d, want 3", queued)
+	}
+	if evicted := pool.Metrics().QueuedEvicted; evicted != 3 {
+		t.Errorf("evictions mismatch: have %d, want 3", evicted)
+	}
+}
+
+func TestPoolExpire(t *testing.T) {
+	pool, state := newTestPool()
+	pool.Lifetime = time.Hour
+	a := newTestAccount(state, 100000000)
+	b := newTestAccount(state, 100000000)
+
+	pool.NewTx(a.tx(2, 1))
+	pool.NewTx(a.tx(3, 1))
+	pool.NewTx(b.tx(1, 1))
+	pool.beats[a.addr] = time.Now().Add(-2 * time.Hour)
+
+	pool.NewTx(b.tx(2, 1))
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Errorf("stats mismatch: have %d/%d, want 2/0", pending, queued)
+	}
+	if expired := pool.Metrics().QueuedExpired; expired != 2 {
+		t.Errorf("expired mismatch: have %d, want 2", expired)
+	}
+	if len(pool.all) != 2 {
+		t.Errorf("expired txs still known: %d", len(pool.all))
+	}
+
+	// without new transactions coming in, popping expires them as well
+	pool.NewTx(b.tx(4, 1))
+	pool.beats[b.addr] = time.Now().Add(-2 * time.Hour)
+	pool.Pop()
+	if _, queued := pool.Stats(); queued != 0 {
+		t.Errorf("queued mismatch: have %d, want 0", queued)
+	}
+	if expired := pool.Metrics().QueuedExpired; expired != 3 {
+		t.Errorf("expired mismatch: have %d, want 3", expired)
+	}
+}
@@ -0,0 +1,145 @@
+package txpool
+
+import (
+	"cxchain223/statemachine"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+)
+
+// Reset moves the pool to the state after newHead. Transactions included
+// in the blocks up to newHead are dropped, those of blocks reorganized out
+// since oldHead and those popped but not included are added again, and the
+// rest is checked against the new state: used nonces and transactions the
+// sender can't pay for anymore are dropped, and pending transactions that
+// are no longer executable in a row are moved back to the queue. The
+// pending transactions are ordered by their tip over the base fee of the
+// block after newHead from then on.
+func (pool *DefaultPool) Reset(oldHead, newHead *Head) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var reinject []*types.Transaction
+	if oldHead != nil && oldHead.Hash != newHead.ParentHash && pool.Chain != nil {
+		reinject = pool.reorged(oldHead, newHead)
+	}
+
+	// popped transactions are added back, those that made it into a
+	// block are then rejected as known to the state
+	for _, popped := range pool.popped {
+		reinject = append(reinject, popped...)
+	}
+	pool.popped = make(map[types.Address][]*types.Transaction)
+
+	pool.expire()
+	pool.Stat.SetStatRoot(newHead.Root)
+	pool.BaseFee = newHead.BaseFee
+
+	senders := make(map[types.Address]bool)
+	for from := range pool.pending {
+		senders[from] = true
+	}
+	for from := range pool.queue {
+		senders[from] = true
+	}
+	for from := range senders {
+		pool.revalidate(from)
+	}
+
+	types.RecoverSenders(pool.ChainID, reinject)
+	for _, tx := range reinject {
+		// included again or invalid now, either way it is not needed
+		pool.add(tx)
+	}
+}
+
+// reorged returns the transactions of the blocks between the common
+// ancestor of both heads and oldHead that are not also in a block up to
+// newHead. It gives up, returning nil, if a block is missing.
+func (pool *DefaultPool) reorged(oldHead, newHead *Head) []*types.Transaction {
+	var discarded, included []types.Transaction
+	rem, add := oldHead, newHead
+	for rem.Height > add.Height {
+		discarded = append(discarded, pool.Chain.GetTransactions(rem.Hash)...)
+		if rem = pool.Chain.GetHead(rem.ParentHash); rem == nil {
+			return nil
+		}
+	}
+	for add.Height > rem.Height {
+		included = append(included, pool.Chain.GetTransactions(add.Hash)...)
+		if add = pool.Chain.GetHead(add.ParentHash); add == nil {
+			return nil
+		}
+	}
+	for rem.Hash != add.Hash {
+		discarded = append(discarded, pool.Chain.GetTransactions(rem.Hash)...)
+		included = append(included, pool.Chain.GetTransactions(add.Hash)...)
+		if rem = pool.Chain.GetHead(rem.ParentHash); rem == nil {
+			return nil
+		}
+		if add = pool.Chain.GetHead(add.ParentHash); add == nil {
+			return nil
+		}
+	}
+
+	known := make(map[hash.Hash]bool, len(included))
+	for i := range included {
+		known[included[i].Hash()] = true
+	}
+	var reinject []*types.Transaction
+	for i := range discarded {
+		if tx := &discarded[i]; !known[tx.Hash()] {
+			reinject = append(reinject, tx)
+		}
+	}
+	return reinject
+}
+
+// revalidate checks the transactions of from against the current state.
+func (pool *DefaultPool) revalidate(from types.Address) {
+	account := pool.Stat.Load(from)
+	if account == nil {
+		account = &types.Account{}
+	}
+	// valid reports whether tx can still be executed at some point
+	valid := func(tx *types.Transaction) bool {
+		if tx.Nonce() <= account.Nonce {
+			return false
+		}
+		// the owners of a multisig account may have changed
+		signers, _ := types.Signers(pool.ChainID, tx)
+		if statemachine.CheckMultisig(account, from, signers) != nil {
+			return false
+		}
+		cost, overflow := tx.Cost()
+		return !overflow && !account.Amount.Lt(cost)
+	}
+
+	if list := pool.pending[from]; list != nil {
+		pending := list.Truncate(0)
+		delete(pool.pending, from)
+		next := account.Nonce + 1
+		for _, tx := range pending {
+			switch {
+			case !valid(tx):
+				delete(pool.all, tx.Hash())
+			case tx.Nonce() == next:
+				pool.pushPendingTx(from, tx)
+				next++
+			default:
+				// a gap opened up before it
+				pool.addQueueTx(from, tx)
+			}
+		}
+	}
+	list := pool.queue[from]
+	for i := 0; i < len(list); {
+		if valid(list[i]) {
+			i++
+			continue
+		}
+		delete(pool.all, list[i].Hash())
+		pool.removeQueued(from, i)
+		list = pool.queue[from]
+	}
+	pool.promote(from)
+}
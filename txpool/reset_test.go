@@ -0,0 +1,153 @@
+package txpool
+
+import (
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// rootedStat serves a separate in-memory state per root.
+type rootedStat struct {
+	*statdb.CacheDB
+	roots map[hash.Hash]*statdb.CacheDB
+}
+
+func (s *rootedStat) SetStatRoot(root hash.Hash) {
+	s.CacheDB = s.roots[root]
+}
+
+// testChain is a set of blocks, each a head and its transactions.
+type testChain map[hash.Hash]testBlock
+
+type testBlock struct {
+	head *Head
+	txs  []types.Transaction
+}
+
+func (c testChain) GetHead(h hash.Hash) *Head {
+	if block, ok := c[h]; ok {
+		return block.head
+	}
+	return nil
+}
+
+func (c testChain) GetTransactions(h hash.Hash) []types.Transaction {
+	return c[h].txs
+}
+
+func (c testChain) add(h, parent hash.Hash, height uint64, txs ...*types.Transaction) *Head {
+	head := &Head{Hash: h, ParentHash: parent, Height: height, Root: h}
+	block := testBlock{head: head}
+	for _, tx := range txs {
+		block.txs = append(block.txs, *tx)
+	}
+	c[h] = block
+	return head
+}
+
+func newResetPool() (*DefaultPool, *rootedStat, testAccount) {
+	genesis := statdb.NewMemoryDB()
+	a := newTestAccount(genesis, 100000000)
+	stat := &rootedStat{CacheDB: genesis, roots: map[hash.Hash]*statdb.CacheDB{{}: genesis}}
+	return NewDefaultPool(1, stat, nil), stat, a
+}
+
+func TestPoolReset(t *testing.T) {
+	pool, stat, a := newResetPool()
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		pool.NewTx(a.tx(nonce, 1))
+	}
+	pool.NewTx(a.tx(5, 1))
+	pool.Pop()
+
+	// the next block includes nonces 1 and 2, the popped one is not added
+	// back
+	next := statdb.NewMemoryDB()
+	next.Store(a.addr, types.Account{Amount: *uint256.NewInt(100000000), Nonce: 2})
+	stat.roots[hash.Hash{1}] = next
+	pool.Reset(&Head{}, &Head{Hash: hash.Hash{1}, Height: 1, Root: hash.Hash{1}})
+
+	if list := pool.pending[a.addr]; list == nil || list.Len() != 1 || list.First().Nonce() != 3 {
+		t.Fatalf("pending mismatch after inclusion")
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 || len(pool.all) != 2 {
+		t.Errorf("stats mismatch: have %d/%d, known %d", pending, queued, len(pool.all))
+	}
+	pool.NewTx(a.tx(4, 1))
+	if txs := popAll(pool); len(txs) != 3 {
+		t.Errorf("popped %d, want 3", len(txs))
+	}
+}
+
+func TestPoolResetDemote(t *testing.T) {
+	pool, stat, a := newResetPool()
+	pool.NewTx(a.tx(1, 1))
+	pool.NewTx(a.tx(2, 100))
+	pool.NewTx(a.tx(3, 1))
+	popped := pool.Pop()
+
+	// the balance left can't pay for nonce 2 anymore, so nonce 3 has to
+	// wait, and the popped tx was not included after all
+	next := statdb.NewMemoryDB()
+	next.Store(a.addr, types.Account{Amount: *uint256.NewInt(1000000)})
+	stat.roots[hash.Hash{1}] = next
+	pool.Reset(&Head{}, &Head{Hash: hash.Hash{1}, Height: 1, Root: hash.Hash{1}})
+
+	if list := pool.pending[a.addr]; list == nil || list.Len() != 1 || list.First().Hash() != popped.Hash() {
+		t.Fatalf("popped tx not added back")
+	}
+	if list := pool.queue[a.addr]; len(list) != 1 || list[0].Nonce() != 3 {
+		t.Fatalf("queue mismatch after demotion")
+	}
+	if txs := popAll(pool); len(txs) != 1 || txs[0].Hash() != popped.Hash() {
+		t.Errorf("popped %d, want only nonce 1", len(txs))
+	}
+}
+
+func TestPoolResetReorg(t *testing.T) {
+	pool, stat, a := newResetPool()
+	chain := make(testChain)
+	pool.Chain = chain
+
+	tx1, tx2 := a.tx(1, 1), a.tx(2, 1)
+	genesis := chain.add(hash.Hash{}, hash.Hash{}, 0)
+	old := chain.add(hash.Hash{0xb1}, genesis.Hash, 1, tx1, tx2)
+	side := chain.add(hash.Hash{0xc1}, genesis.Hash, 1, tx1)
+	head := chain.add(hash.Hash{0xc2}, side.Hash, 2)
+
+	// the new branch only includes tx1
+	next := statdb.NewMemoryDB()
+	next.Store(a.addr, types.Account{Amount: *uint256.NewInt(100000000), Nonce: 1})
+	stat.roots[head.Root] = next
+	pool.Reset(old, head)
+
+	if list := pool.pending[a.addr]; list == nil || list.Len() != 1 || list.First().Hash() != tx2.Hash() {
+		t.Fatalf("reorged tx not re-injected")
+	}
+	if len(pool.all) != 1 {
+		t.Errorf("known mismatch: have %d, want 1", len(pool.all))
+	}
+}
+
+func TestPoolResetBaseFee(t *testing.T) {
+	pool, stat, a := newResetPool()
+	b := newTestAccount(stat.CacheDB, 100000000)
+	paying := b.tx(1, 10)
+	pool.NewTx(a.tx(1, 1))
+	pool.NewTx(paying)
+
+	// only the tx paying the base fee of the next block is popped
+	pool.Reset(&Head{}, &Head{Height: 1, BaseFee: *uint256.NewInt(5)})
+	if pool.BaseFee.Uint64() != 5 {
+		t.Errorf("base fee mismatch: have %v, want 5", &pool.BaseFee)
+	}
+	if tx := pool.Pop(); tx == nil || tx.Hash() != paying.Hash() {
+		t.Fatalf("pop mismatch: have %v", tx)
+	}
+	if tx := pool.Pop(); tx != nil {
+		t.Errorf("popped tx below the base fee: nonce %d", tx.Nonce())
+	}
+}
@@ -3,11 +3,36 @@ package txpool
 import (
 	"cxchain223/types"
 	"cxchain223/utils/hash"
+
+	"github.com/holiman/uint256"
 )
 
 type TxPool interface {
-	SetStatRoot(root hash.Hash)
-	NewTx(tx *types.Transaction)
+	NewTx(tx *types.Transaction) error
+	NewTxs(txs []*types.Transaction) []error
 	Pop() *types.Transaction
-	NotifyTxEvent(txs []*types.Transaction)
+	// GiveBack returns tx, the last one Pop returned of its sender, to the
+	// pool without checking it again, as it was not put in a block.
+	GiveBack(tx *types.Transaction) error
+	// Discard reports that tx, returned by Pop, failed to execute, so the
+	// later transactions of its sender wait until the nonce is used.
+	Discard(tx *types.Transaction)
+	// Reset moves the pool from oldHead to newHead, nil if the pool has
+	// not followed a head yet.
+	Reset(oldHead, newHead *Head)
+}
+
+// Head is a block of the chain as far as the pool is concerned.
+type Head struct {
+	Hash       hash.Hash
+	ParentHash hash.Hash
+	Height     uint64
+	Root       hash.Hash   // state root after the block
+	BaseFee    uint256.Int // base fee of the child of the block
+}
+
+// Chain gives the pool the blocks between two heads.
+type Chain interface {
+	GetHead(h hash.Hash) *Head
+	GetTransactions(h hash.Hash) []types.Transaction
 }
@@ -1,11 +1,69 @@
 package types
 
-import "hash"
+import (
+	"bytes"
+	"cxchain223/utils/hash"
+	"cxchain223/utils/rlp"
+
+	"github.com/holiman/uint256"
+)
 
 type Account struct {
-	Amount uint64
+	Amount uint256.Int
 	Nonce  uint64
 
 	CodeHash hash.Hash
 	Root     hash.Hash
+
+	Multisig *Multisig `rlp:"optional"` // set for multisig accounts
+}
+
+// Multisig is the owner set of a multisig account. Transactions from the
+// account need the signatures of at least Threshold distinct owners. It is
+// shared by copies of the account, so it is replaced rather than modified.
+type Multisig struct {
+	Threshold uint64
+	Owners    []Address
+}
+
+// Approved reports whether the distinct owners among signers reach the
+// threshold.
+func (m *Multisig) Approved(signers []Address) bool {
+	approved := make(map[Address]bool, len(signers))
+	for _, signer := range signers {
+		for _, owner := range m.Owners {
+			if signer == owner {
+				approved[signer] = true
+			}
+		}
+	}
+	return uint64(len(approved)) >= m.Threshold
+}
+
+// legacyAccount is the layout written before the code hash and storage
+// root were fixed size hashes. Both were always empty and encoded as empty
+// lists, and the amount was a uint64, which shares its encoding with
+// uint256.
+type legacyAccount struct {
+	Amount   uint256.Int
+	Nonce    uint64
+	CodeHash rlp.RawValue
+	Root     rlp.RawValue
+}
+
+// DecodeAccount decodes an RLP encoded account. Accounts in the legacy
+// layout are accepted as well and come back with zero hashes; storing the
+// result again writes the current layout, so databases are migrated as
+// their accounts are touched.
+func DecodeAccount(data []byte) (*Account, error) {
+	var account Account
+	err := rlp.DecodeBytes(data, &account)
+	if err == nil {
+		return &account, nil
+	}
+	var legacy legacyAccount
+	if rlp.DecodeBytes(data, &legacy) != nil || !bytes.Equal(legacy.CodeHash, rlp.EmptyList) || !bytes.Equal(legacy.Root, rlp.EmptyList) {
+		return nil, err
+	}
+	return &Account{Amount: legacy.Amount, Nonce: legacy.Nonce}, nil
 }
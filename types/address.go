@@ -1,10 +1,46 @@
 package types
 
+import (
+	"cxchain223/crypto/sha3"
+	"cxchain223/utils/hexutil"
+	"cxchain223/utils/rlp"
+	"reflect"
+)
+
 type Address [20]byte
 
+var addressT = reflect.TypeOf(Address{})
+
+// MarshalText returns the hex representation of a.
+func (a Address) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(a[:]).MarshalText()
+}
+
+// UnmarshalText parses an address in hex syntax.
+func (a *Address) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("Address", input, a[:])
+}
+
+// UnmarshalJSON parses an address in hex syntax.
+func (a *Address) UnmarshalJSON(input []byte) error {
+	return hexutil.UnmarshalFixedJSON(addressT, input, a[:])
+}
+
+// PubKeyToAddress derives the address of a 65 byte uncompressed public key
+// as the last 20 bytes of the keccak hash of its X and Y coordinates.
 func PubKeyToAddress(pub []byte) Address {
-	// h := sha3.Keccak256(pub)
+	h := sha3.Keccak256(pub[1:])
+	var addr Address
+	copy(addr[:], h[12:])
+	return addr
+}
+
+// CreateAddress derives the address of a contract created by sender with
+// the given transaction nonce, as the last 20 bytes of keccak(rlp([sender, nonce])).
+func CreateAddress(sender Address, nonce uint64) Address {
+	data, _ := rlp.EncodeToBytes([]interface{}{sender, nonce})
+	h := sha3.Keccak256(data)
 	var addr Address
-	// TODO hash得到addr
+	copy(addr[:], h[12:])
 	return addr
 }
@@ -0,0 +1,71 @@
+package types
+
+import "cxchain223/crypto/sha3"
+
+const (
+	BloomByteLength = 256
+	BloomBitLength  = 8 * BloomByteLength
+)
+
+// Bloom is a 2048 bit bloom filter over the addresses and topics of logs.
+// A negative Test is certain, a positive one may be a false positive.
+type Bloom [BloomByteLength]byte
+
+// Add sets the three bits selected by the hash of d.
+func (b *Bloom) Add(d []byte) {
+	for _, bit := range bloomBits(d) {
+		b[BloomByteLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test reports whether d may have been added to the filter.
+func (b Bloom) Test(d []byte) bool {
+	for _, bit := range bloomBits(d) {
+		if b[BloomByteLength-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Or merges other into b.
+func (b *Bloom) Or(other Bloom) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+func (b Bloom) Bytes() []byte {
+	return b[:]
+}
+
+// bloomBits returns the bits d maps to: the low 11 bits of each of the
+// first three byte pairs of keccak(d).
+func bloomBits(d []byte) [3]uint {
+	h := sha3.Keccak256(d)
+	var bits [3]uint
+	for i := range bits {
+		bits[i] = (uint(h[2*i])<<8 | uint(h[2*i+1])) & (BloomBitLength - 1)
+	}
+	return bits
+}
+
+func LogsBloom(logs []*Log) Bloom {
+	var bloom Bloom
+	for _, log := range logs {
+		bloom.Add(log.Address[:])
+		for _, topic := range log.Topics {
+			bloom.Add(topic[:])
+		}
+	}
+	return bloom
+}
+
+// CreateBloom merges the blooms of all receipts of a block.
+func CreateBloom(receipts []Receiption) Bloom {
+	var bloom Bloom
+	for _, receipt := range receipts {
+		bloom.Or(receipt.Bloom)
+	}
+	return bloom
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"cxchain223/utils/hash"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	positive := []string{"testtest", "test", "hallo", "other"}
+	negative := []string{"tes", "lo"}
+
+	var bloom Bloom
+	for _, data := range positive {
+		bloom.Add([]byte(data))
+	}
+	for _, data := range positive {
+		if !bloom.Test([]byte(data)) {
+			t.Errorf("expected %q to be in the bloom", data)
+		}
+	}
+	for _, data := range negative {
+		if bloom.Test([]byte(data)) {
+			t.Errorf("did not expect %q to be in the bloom", data)
+		}
+	}
+}
+
+func TestCreateBloom(t *testing.T) {
+	var (
+		addr  = Address{0xaa}
+		topic = hash.Hash{0xbb}
+	)
+	receipts := []Receiption{
+		{Bloom: LogsBloom([]*Log{{Address: addr}})},
+		{Bloom: LogsBloom([]*Log{{Address: Address{0xcc}, Topics: []hash.Hash{topic}}})},
+		{},
+	}
+	bloom := CreateBloom(receipts)
+	for _, data := range [][]byte{addr[:], topic[:]} {
+		if !bloom.Test(data) {
+			t.Errorf("expected %x to be in the block bloom", data)
+		}
+	}
+	if receipts[0].Bloom.Test(topic[:]) {
+		t.Error("topic of the second receipt found in the first")
+	}
+	if (Bloom{}).Test(addr[:]) {
+		t.Error("empty bloom matched")
+	}
+}
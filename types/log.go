@@ -0,0 +1,17 @@
+package types
+
+import "cxchain223/utils/hash"
+
+// Log is an event emitted during the execution of a transaction. Only
+// Address, Topics and Data are part of the consensus encoding, the rest is
+// filled in when the log is put into its block.
+type Log struct {
+	Address Address
+	Topics  []hash.Hash
+	Data    []byte
+
+	BlockNumber uint64    `rlp:"-"`
+	TxHash      hash.Hash `rlp:"-"`
+	TxIndex     uint      `rlp:"-"`
+	Index       uint      `rlp:"-"` // position in the block
+}
@@ -1,51 +1,296 @@
 package types
 
 import (
-	"cxchain223/crypto/secp256k1"
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+
 	"cxchain223/crypto/sha3"
-	"cxchain223/utils/hexutil"
+	"cxchain223/utils/hash"
 	"cxchain223/utils/rlp"
-	"fmt"
-	"hash"
-	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+var (
+	// ErrGasFeeCapTooLow is returned if a transaction cannot pay the base fee.
+	ErrGasFeeCapTooLow = errors.New("max fee per gas less than block base fee")
+
+	// ErrTxTypeNotSupported is returned when decoding a transaction of an
+	// unknown type.
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+
+	errShortTypedTx = errors.New("typed transaction too short")
+)
+
+// Transaction types, the first byte of the encoding of a typed
+// transaction. Legacy transactions are encoded as a plain RLP list.
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01
+	DynamicFeeTxType = 0x02
+	MultisigTxType   = 0x03
+)
+
+const (
+	ReceiptStatusFailed     = 0
+	ReceiptStatusSuccessful = 1
 )
 
 type Receiption struct {
-	TxHash hash.Hash
-	Status int
-	// GasUsed int
-	// Logs
+	TxHash            hash.Hash
+	Status            int
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	ContractAddress   Address // set if the transaction created a contract
+	Logs              []*Log
+	Bloom             Bloom
 }
 
+// Transaction is the envelope around the data of one of the transaction
+// types. Everything that depends on the type, signing, hashing and the
+// encodings, is dispatched to the inner TxData.
 type Transaction struct {
-	txdata
-	signature
+	inner TxData
+
+	// caches, shared by copies of the transaction
+	hash    *hash.Hash
+	size    *uint64
+	from    *Address  // cache of Sender
+	signers []Address // cache of Signers
+}
+
+// TxData is the data of a transaction of a given type. The methods are
+// unexported, the implementations are the types of this package.
+type TxData interface {
+	txType() byte
+	copy() TxData // deep copy, so the envelope owns its data
+
+	chainID() uint64
+	accessList() AccessList
+	data() []byte
+	gas() uint64
+	gasPrice() *uint256.Int
+	gasTipCap() *uint256.Int
+	gasFeeCap() *uint256.Int
+	value() *uint256.Int
+	nonce() uint64
+	to() *Address
+
+	rawSignatureValues() (v uint8, r, s *big.Int)
+	setSignatureValues(chainID uint64, v uint8, r, s *big.Int)
+
+	// sigHashData returns what the sender signs, without the signature.
+	sigHashData() interface{}
+}
+
+// NewTx creates a transaction wrapping a copy of inner.
+func NewTx(inner TxData) *Transaction {
+	return &Transaction{inner: inner.copy()}
+}
+
+// Type returns the transaction type.
+func (tx *Transaction) Type() uint8 { return tx.inner.txType() }
+
+// ChainID returns the chain the transaction is signed for.
+func (tx *Transaction) ChainID() uint64 { return tx.inner.chainID() }
+
+// AccessList returns the access list of the transaction, nil for legacy
+// transactions.
+func (tx *Transaction) AccessList() AccessList { return tx.inner.accessList() }
+
+// Data returns the input of the transaction.
+func (tx *Transaction) Data() []byte { return bytes.Clone(tx.inner.data()) }
+
+// Gas returns the gas limit of the transaction.
+func (tx *Transaction) Gas() uint64 { return tx.inner.gas() }
+
+// GasPrice returns the gas price of the transaction, the fee cap for
+// dynamic-fee transactions.
+func (tx *Transaction) GasPrice() *uint256.Int { return new(uint256.Int).Set(tx.inner.gasPrice()) }
+
+// GasTipCap returns the most tx pays per gas on top of the base fee.
+func (tx *Transaction) GasTipCap() *uint256.Int { return new(uint256.Int).Set(tx.inner.gasTipCap()) }
+
+// GasFeeCap returns the most tx pays per gas.
+func (tx *Transaction) GasFeeCap() *uint256.Int { return new(uint256.Int).Set(tx.inner.gasFeeCap()) }
+
+// Value returns the amount transferred by the transaction.
+func (tx *Transaction) Value() *uint256.Int { return new(uint256.Int).Set(tx.inner.value()) }
+
+// Nonce returns the sender nonce of the transaction.
+func (tx *Transaction) Nonce() uint64 { return tx.inner.nonce() }
+
+// To returns the recipient of the transaction, nil for contract creation.
+func (tx *Transaction) To() *Address {
+	to := tx.inner.to()
+	if to == nil {
+		return nil
+	}
+	cpy := *to
+	return &cpy
+}
+
+// RawSignatureValues returns the recovery id and the R, S values of the
+// signature, all zero for multisig transactions.
+func (tx *Transaction) RawSignatureValues() (v uint8, r, s *big.Int) {
+	return tx.inner.rawSignatureValues()
+}
+
+// NumSignatures returns the number of signatures of a multisig transaction
+// and 1 for other transactions, without recovering them.
+func (tx *Transaction) NumSignatures() int {
+	if multisig, ok := tx.inner.(*MultisigTx); ok {
+		return len(multisig.Signatures)
+	}
+	return 1
+}
+
+// Hash returns the hash identifying the transaction, the keccak hash of
+// its canonical encoding including the signature.
+func (tx *Transaction) Hash() hash.Hash {
+	if tx.hash != nil {
+		return *tx.hash
+	}
+	enc, _ := tx.MarshalBinary()
+	h := sha3.Keccak256(enc)
+	tx.hash = &h
+	return h
+}
+
+// Size returns the length of the canonical encoding of the transaction.
+func (tx *Transaction) Size() uint64 {
+	if tx.size != nil {
+		return *tx.size
+	}
+	enc, _ := tx.MarshalBinary()
+	size := uint64(len(enc))
+	tx.size = &size
+	return size
 }
 
-type txdata struct {
-	To       Address
-	Nonce    uint64
-	Value    uint64
-	Gas      uint64
-	GasPrice uint64
-	Input    []byte
+// Cost returns value + gas * gas fee cap and whether the computation
+// overflowed.
+func (tx *Transaction) Cost() (*uint256.Int, bool) {
+	cost, overflow := new(uint256.Int).MulOverflow(uint256.NewInt(tx.Gas()), tx.inner.gasFeeCap())
+	if overflow {
+		return nil, true
+	}
+	return cost.AddOverflow(cost, tx.inner.value())
 }
 
-type signature struct {
-	R, S *big.Int
-	V    uint8
+// EffectiveGasTip returns what tx pays per gas on top of baseFee, or
+// ErrGasFeeCapTooLow if it cannot even pay baseFee.
+func (tx *Transaction) EffectiveGasTip(baseFee *uint256.Int) (*uint256.Int, error) {
+	feeCap := tx.GasFeeCap()
+	if feeCap.Lt(baseFee) {
+		return nil, ErrGasFeeCapTooLow
+	}
+	tip := feeCap.Sub(feeCap, baseFee)
+	if tipCap := tx.inner.gasTipCap(); tipCap.Lt(tip) {
+		return tip.Set(tipCap), nil
+	}
+	return tip, nil
 }
 
-func (tx Transaction) From() Address {
-	txdata := tx.txdata
-	toSign, err := rlp.EncodeToBytes(txdata)
-	fmt.Println(hexutil.Encode(toSign), err)
-	msg := sha3.Keccak256(toSign)
-	sig := make([]byte, 65)
-	pubKey, err := secp256k1.RecoverPubkey(msg[:], sig)
+// EncodeRLP writes legacy transactions as an RLP list and typed ones as an
+// RLP string holding type || payload.
+func (tx *Transaction) EncodeRLP(w io.Writer) error {
+	if tx.Type() == LegacyTxType {
+		return rlp.Encode(w, tx.inner)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
+}
+
+// DecodeRLP decodes either encoding written by EncodeRLP.
+func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
+	kind, _, err := s.Kind()
 	if err != nil {
-		// TODO 返回一个空地址
-		panic(err)
+		return err
+	}
+	if kind == rlp.List {
+		var inner LegacyTx
+		if err := s.Decode(&inner); err != nil {
+			return err
+		}
+		tx.setDecoded(&inner)
+		return nil
+	}
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	return tx.UnmarshalBinary(b)
+}
+
+// MarshalBinary returns the canonical encoding of the transaction, the
+// RLP list for legacy transactions and type || RLP payload otherwise.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.Type() == LegacyTxType {
+		return rlp.EncodeToBytes(tx.inner)
+	}
+	payload, err := rlp.EncodeToBytes(tx.inner)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.Type()}, payload...), nil
+}
+
+// UnmarshalBinary decodes the canonical encoding of a transaction.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > 0x7f {
+		// a list, so a legacy transaction
+		var inner LegacyTx
+		if err := rlp.DecodeBytes(b, &inner); err != nil {
+			return err
+		}
+		tx.setDecoded(&inner)
+		return nil
+	}
+	if len(b) <= 1 {
+		return errShortTypedTx
+	}
+	var inner TxData
+	switch b[0] {
+	case AccessListTxType:
+		inner = new(AccessListTx)
+	case DynamicFeeTxType:
+		inner = new(DynamicFeeTx)
+	case MultisigTxType:
+		inner = new(MultisigTx)
+	default:
+		return ErrTxTypeNotSupported
+	}
+	if err := rlp.DecodeBytes(b[1:], inner); err != nil {
+		return err
+	}
+	tx.setDecoded(inner)
+	return nil
+}
+
+func (tx *Transaction) setDecoded(inner TxData) {
+	tx.inner = inner
+	tx.hash, tx.size, tx.from, tx.signers = nil, nil, nil, nil
+}
+
+// copyBig returns a copy of b, nil stays nil.
+func copyBig(b *big.Int) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).Set(b)
+}
+
+// copyAddress returns a copy of a, nil stays nil.
+func copyAddress(a *Address) *Address {
+	if a == nil {
+		return nil
 	}
-	return PubKeyToAddress(pubKey)
+	cpy := *a
+	return &cpy
 }
@@ -0,0 +1,207 @@
+package types
+
+import (
+	"cxchain223/utils/hash"
+	"cxchain223/utils/hexutil"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// txJSON is the JSON representation of all transaction types, which fields
+// are set depends on the type. Hash is only written, it is recomputed when
+// a transaction is read.
+type txJSON struct {
+	Hash *hash.Hash     `json:"hash,omitempty"`
+	Type hexutil.Uint64 `json:"type"`
+
+	ChainID              *hexutil.Uint64 `json:"chainId,omitempty"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	To                   *Address        `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.U256   `json:"gasPrice"`
+	MaxPriorityFeePerGas *hexutil.U256   `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         *hexutil.U256   `json:"maxFeePerGas,omitempty"`
+	Value                *hexutil.U256   `json:"value"`
+	Input                *hexutil.Bytes  `json:"input"`
+	AccessList           *AccessList     `json:"accessList,omitempty"`
+
+	V *hexutil.Uint64 `json:"v,omitempty"`
+	R *hexutil.Big    `json:"r,omitempty"`
+	S *hexutil.Big    `json:"s,omitempty"`
+
+	// multisig transactions only
+	From       *Address        `json:"from,omitempty"`
+	Signatures []signatureJSON `json:"signatures,omitempty"`
+}
+
+type signatureJSON struct {
+	V hexutil.Uint64 `json:"v"`
+	R *hexutil.Big   `json:"r"`
+	S *hexutil.Big   `json:"s"`
+}
+
+// MarshalJSON encodes tx with the fields of its type.
+func (tx *Transaction) MarshalJSON() ([]byte, error) {
+	var enc txJSON
+	h := tx.Hash()
+	enc.Hash = &h
+	enc.Type = hexutil.Uint64(tx.Type())
+	chainID := hexutil.Uint64(tx.ChainID())
+	enc.ChainID = &chainID
+	nonce := hexutil.Uint64(tx.Nonce())
+	enc.Nonce = &nonce
+	enc.To = tx.To()
+	gas := hexutil.Uint64(tx.Gas())
+	enc.Gas = &gas
+	enc.GasPrice = (*hexutil.U256)(tx.GasPrice())
+	enc.Value = (*hexutil.U256)(tx.Value())
+	input := hexutil.Bytes(tx.Data())
+	enc.Input = &input
+
+	switch tx.Type() {
+	case AccessListTxType:
+		al := tx.AccessList()
+		enc.AccessList = &al
+	case DynamicFeeTxType:
+		al := tx.AccessList()
+		enc.AccessList = &al
+		enc.MaxPriorityFeePerGas = (*hexutil.U256)(tx.GasTipCap())
+		enc.MaxFeePerGas = (*hexutil.U256)(tx.GasFeeCap())
+	case MultisigTxType:
+		multisig := tx.inner.(*MultisigTx)
+		from := multisig.From
+		enc.From = &from
+		enc.Signatures = make([]signatureJSON, len(multisig.Signatures))
+		for i, sig := range multisig.Signatures {
+			enc.Signatures[i] = signatureJSON{V: hexutil.Uint64(sig.V), R: (*hexutil.Big)(sig.R), S: (*hexutil.Big)(sig.S)}
+		}
+		return json.Marshal(&enc)
+	}
+
+	v, r, s := tx.RawSignatureValues()
+	vq := hexutil.Uint64(v)
+	enc.V = &vq
+	if r != nil {
+		enc.R = (*hexutil.Big)(r)
+	}
+	if s != nil {
+		enc.S = (*hexutil.Big)(s)
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a transaction encoded by MarshalJSON.
+func (tx *Transaction) UnmarshalJSON(input []byte) error {
+	var dec txJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Nonce == nil {
+		return errors.New("missing required field 'nonce' in transaction")
+	}
+	if dec.Gas == nil {
+		return errors.New("missing required field 'gas' in transaction")
+	}
+	if dec.Input == nil {
+		return errors.New("missing required field 'input' in transaction")
+	}
+	var chainID uint64
+	if dec.ChainID != nil {
+		chainID = uint64(*dec.ChainID)
+	}
+	var value uint256.Int
+	if dec.Value != nil {
+		value = uint256.Int(*dec.Value)
+	}
+	var accessList AccessList
+	if dec.AccessList != nil {
+		accessList = *dec.AccessList
+	}
+
+	var inner TxData
+	switch dec.Type {
+	case LegacyTxType, AccessListTxType:
+		if dec.GasPrice == nil {
+			return errors.New("missing required field 'gasPrice' in transaction")
+		}
+		if dec.Type == LegacyTxType {
+			inner = &LegacyTx{
+				To:       dec.To,
+				Nonce:    uint64(*dec.Nonce),
+				Value:    value,
+				Gas:      uint64(*dec.Gas),
+				GasPrice: uint256.Int(*dec.GasPrice),
+				Data:     *dec.Input,
+				ChainID:  chainID,
+			}
+		} else {
+			inner = &AccessListTx{
+				ChainID:    chainID,
+				Nonce:      uint64(*dec.Nonce),
+				GasPrice:   uint256.Int(*dec.GasPrice),
+				Gas:        uint64(*dec.Gas),
+				To:         dec.To,
+				Value:      value,
+				Data:       *dec.Input,
+				AccessList: accessList,
+			}
+		}
+	case DynamicFeeTxType:
+		if dec.MaxPriorityFeePerGas == nil {
+			return errors.New("missing required field 'maxPriorityFeePerGas' in transaction")
+		}
+		if dec.MaxFeePerGas == nil {
+			return errors.New("missing required field 'maxFeePerGas' in transaction")
+		}
+		inner = &DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      uint64(*dec.Nonce),
+			GasTipCap:  uint256.Int(*dec.MaxPriorityFeePerGas),
+			GasFeeCap:  uint256.Int(*dec.MaxFeePerGas),
+			Gas:        uint64(*dec.Gas),
+			To:         dec.To,
+			Value:      value,
+			Data:       *dec.Input,
+			AccessList: accessList,
+		}
+	case MultisigTxType:
+		if dec.GasPrice == nil {
+			return errors.New("missing required field 'gasPrice' in transaction")
+		}
+		if dec.From == nil {
+			return errors.New("missing required field 'from' in transaction")
+		}
+		multisig := &MultisigTx{
+			ChainID:  chainID,
+			Nonce:    uint64(*dec.Nonce),
+			GasPrice: uint256.Int(*dec.GasPrice),
+			Gas:      uint64(*dec.Gas),
+			From:     *dec.From,
+			To:       dec.To,
+			Value:    value,
+			Data:     *dec.Input,
+		}
+		for _, sig := range dec.Signatures {
+			if sig.V > 1 || sig.R == nil || sig.S == nil {
+				return ErrInvalidSig
+			}
+			multisig.Signatures = append(multisig.Signatures, Signature{V: uint8(sig.V), R: (*big.Int)(sig.R), S: (*big.Int)(sig.S)})
+		}
+		tx.setDecoded(multisig)
+		return nil
+	default:
+		return ErrTxTypeNotSupported
+	}
+
+	if dec.V != nil && dec.R != nil && dec.S != nil {
+		if *dec.V > 1 {
+			return ErrInvalidSig
+		}
+		inner.setSignatureValues(chainID, uint8(*dec.V), (*big.Int)(dec.R), (*big.Int)(dec.S))
+	}
+	tx.setDecoded(inner)
+	return nil
+}
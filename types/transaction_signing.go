@@ -0,0 +1,168 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"cxchain223/crypto"
+	"cxchain223/crypto/secp256k1"
+	"cxchain223/crypto/sha3"
+	"cxchain223/utils/rlp"
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+var (
+	// ErrInvalidSig is returned if the signature of a transaction is
+	// malformed, malleable or does not recover to a public key.
+	ErrInvalidSig = errors.New("invalid transaction v, r, s values")
+
+	// ErrInvalidChainId is returned if a transaction was signed for
+	// another chain.
+	ErrInvalidChainId = errors.New("invalid chain id for signer")
+
+	// ErrTooManySignatures is returned if a multisig transaction carries
+	// more than MaxSignatures signatures.
+	ErrTooManySignatures = errors.New("too many signatures")
+)
+
+// sigHash returns the hash the sender signs. For legacy transactions it is
+// the keccak hash of the RLP encoding of the transaction data including
+// the chain ID, typed transactions prefix the encoding with their type.
+func (tx *Transaction) sigHash() ([]byte, error) {
+	data, err := rlp.EncodeToBytes(tx.inner.sigHashData())
+	if err != nil {
+		return nil, err
+	}
+	if tx.Type() != LegacyTxType {
+		data = append([]byte{tx.Type()}, data...)
+	}
+	h := sha3.Keccak256(data)
+	return h[:], nil
+}
+
+// SignTx returns a copy of tx for the given chain, signed with prv.
+func SignTx(tx *Transaction, chainID uint64, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	signed := &Transaction{inner: tx.inner.copy()}
+	signed.inner.setSignatureValues(chainID, 0, nil, nil)
+	h, err := signed.sigHash()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(h, prv)
+	if err != nil {
+		return nil, err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	signed.inner.setSignatureValues(chainID, sig[crypto.RecoveryIDOffset], r, s)
+	return signed, nil
+}
+
+// Sender recovers the address that signed tx for the given chain. Only
+// signatures in the lower half of the S range are accepted, so a signed
+// transaction cannot be altered into another valid one. The result is
+// cached on tx, so later calls and copies of tx don't recover it again.
+//
+// For a multisig transaction the sender is the From address it claims,
+// which is not verified here: the signatures only have to recover, at most
+// MaxSignatures of them. Whether the signers returned by Signers own From
+// and reach its threshold depends on the state, see Multisig.Approved.
+func Sender(chainID uint64, tx *Transaction) (Address, error) {
+	if tx.ChainID() != chainID {
+		return Address{}, fmt.Errorf("%w: have %d want %d", ErrInvalidChainId, tx.ChainID(), chainID)
+	}
+	if tx.from != nil {
+		return *tx.from, nil
+	}
+	h, err := tx.sigHash()
+	if err != nil {
+		return Address{}, err
+	}
+	if multisig, ok := tx.inner.(*MultisigTx); ok {
+		if len(multisig.Signatures) == 0 {
+			return Address{}, ErrInvalidSig
+		}
+		if n := len(multisig.Signatures); n > MaxSignatures {
+			return Address{}, fmt.Errorf("%w: have %d, max %d", ErrTooManySignatures, n, MaxSignatures)
+		}
+		signers := make([]Address, len(multisig.Signatures))
+		for i, sig := range multisig.Signatures {
+			if signers[i], err = recoverPlain(h, sig.V, sig.R, sig.S); err != nil {
+				return Address{}, err
+			}
+		}
+		from := multisig.From
+		tx.from, tx.signers = &from, signers
+		return from, nil
+	}
+	v, r, s := tx.RawSignatureValues()
+	addr, err := recoverPlain(h, v, r, s)
+	if err != nil {
+		return Address{}, err
+	}
+	tx.from = &addr
+	return addr, nil
+}
+
+// Signers returns the addresses that signed a multisig transaction for the
+// given chain, in the order of its signatures, and nil for other
+// transactions.
+func Signers(chainID uint64, tx *Transaction) ([]Address, error) {
+	if _, err := Sender(chainID, tx); err != nil {
+		return nil, err
+	}
+	return append([]Address(nil), tx.signers...), nil
+}
+
+// recoverPlain recovers the address that signed h.
+func recoverPlain(h []byte, v uint8, r, s *big.Int) (Address, error) {
+	if r == nil || s == nil || !crypto.ValidateSignatureValues(v, r, s, true) {
+		return Address{}, ErrInvalidSig
+	}
+	// R || S || V
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[crypto.RecoveryIDOffset] = v
+	pub, err := secp256k1.RecoverPubkey(h, sig)
+	if err != nil {
+		return Address{}, fmt.Errorf("%w: %v", ErrInvalidSig, err)
+	}
+	return PubKeyToAddress(pub), nil
+}
+
+// RecoverSenders recovers the senders of txs for the given chain on all
+// CPUs and caches them on the transactions, so Sender returns them right
+// away. Transactions failing recovery are left alone, Sender reports their
+// error.
+func RecoverSenders(chainID uint64, txs []*Transaction) {
+	// every transaction is handed to a single worker, as the cache is
+	// written without locking
+	work := make(chan *Transaction, len(txs))
+	seen := make(map[*Transaction]bool, len(txs))
+	for _, tx := range txs {
+		if !seen[tx] && tx.from == nil && tx.ChainID() == chainID {
+			seen[tx] = true
+			work <- tx
+		}
+	}
+	close(work)
+
+	workers := runtime.NumCPU()
+	if len(work) < workers {
+		workers = len(work)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for tx := range work {
+				Sender(chainID, tx)
+			}
+		}()
+	}
+	wg.Wait()
+}
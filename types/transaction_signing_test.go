@@ -0,0 +1,180 @@
+package types
+
+import (
+	"crypto/ecdsa"
+	"cxchain223/crypto"
+	"cxchain223/utils/hexutil"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestPubKeyToAddress(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	want := hexutil.MustDecode("0x71562b71999873db5b286df957af199ec94617f7")
+	if addr := PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey)); string(addr[:]) != string(want) {
+		t.Errorf("address mismatch: have %x, want %x", addr, want)
+	}
+}
+
+func TestSignTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	want := PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey))
+	tx := NewTx(&LegacyTx{To: &Address{0xaa}, Nonce: 1, Value: *uint256.NewInt(5), Gas: 21000})
+
+	if _, err := Sender(0, tx); err != ErrInvalidSig {
+		t.Errorf("unsigned tx: error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+	signed, err := SignTx(tx, 1, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := Sender(1, signed)
+	if err != nil || from != want {
+		t.Fatalf("sender mismatch: have %x, %v, want %x", from, err, want)
+	}
+	if signed.from == nil || *signed.from != want {
+		t.Error("sender not cached")
+	}
+	// copies share the cache
+	if cpy := *signed; cpy.from == nil {
+		t.Error("cache lost on copy")
+	}
+
+	// a modified transaction recovers to somebody else
+	tampered := NewTx(signed.inner)
+	tampered.inner.(*LegacyTx).Nonce = 2
+	if from, err := Sender(1, tampered); err == nil && from == want {
+		t.Error("tampered transaction still recovers the signer")
+	}
+	// out of range values are rejected
+	bad := NewTx(signed.inner)
+	bad.inner.(*LegacyTx).V = 2
+	if _, err := Sender(1, bad); err != ErrInvalidSig {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+	bad = NewTx(signed.inner)
+	bad.inner.(*LegacyTx).R = new(big.Int)
+	if _, err := Sender(1, bad); err != ErrInvalidSig {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+}
+
+func TestSenderChainID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	want := PubKeyToAddress(crypto.FromECDSAPub(&key.PublicKey))
+	tx := NewTx(&DynamicFeeTx{To: &Address{0xaa}, Nonce: 1, Gas: 21000})
+	signed, err := SignTx(tx, 5, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.ChainID() != 5 {
+		t.Errorf("chain id mismatch: have %d, want 5", signed.ChainID())
+	}
+	if _, err := Sender(1, signed); !errors.Is(err, ErrInvalidChainId) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+	// even once the sender is cached
+	if from, err := Sender(5, signed); err != nil || from != want {
+		t.Fatalf("sender mismatch: have %x, %v, want %x", from, err, want)
+	}
+	if _, err := Sender(1, signed); !errors.Is(err, ErrInvalidChainId) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+
+	// replaying the signature on another chain recovers somebody else
+	replayed := NewTx(signed.inner)
+	replayed.inner.(*DynamicFeeTx).ChainID = 1
+	if from, err := Sender(1, replayed); err == nil && from == want {
+		t.Error("signature valid on another chain")
+	}
+}
+
+func TestSenderHighS(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx := NewTx(&LegacyTx{To: &Address{0xaa}, Nonce: 1, Gas: 21000})
+	signed, err := SignTx(tx, 1, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (r, n - s) with the other recovery id is the same signature, malleated
+	n := crypto.S256().Params().N
+	v, r, s := signed.RawSignatureValues()
+	malleated := NewTx(signed.inner)
+	inner := malleated.inner.(*LegacyTx)
+	inner.R, inner.S, inner.V = r, new(big.Int).Sub(n, s), v^1
+	if _, err := Sender(1, malleated); err != ErrInvalidSig {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+}
+
+func TestRecoverSenders(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	var txs []*Transaction
+	for i := 0; i < 64; i++ {
+		tx := NewTx(&LegacyTx{To: &Address{0xaa}, Nonce: uint64(i + 1), Gas: 21000})
+		signed, err := SignTx(tx, 1, keys[i%len(keys)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, signed)
+	}
+	otherChain, _ := SignTx(txs[0], 2, keys[0])
+	unsigned := NewTx(&LegacyTx{To: &Address{0xaa}, Nonce: 1, Gas: 21000, ChainID: 1})
+	// duplicates must not be recovered twice at the same time
+	txs = append(txs, txs[0], otherChain, unsigned)
+
+	RecoverSenders(1, txs)
+	for i, tx := range txs[:64] {
+		want := PubKeyToAddress(crypto.FromECDSAPub(&keys[i%len(keys)].PublicKey))
+		if tx.from == nil || *tx.from != want {
+			t.Fatalf("tx %d: sender not cached", i)
+		}
+	}
+	if otherChain.from != nil || unsigned.from != nil {
+		t.Error("sender cached for unrecoverable transaction")
+	}
+	if _, err := Sender(1, unsigned); err != ErrInvalidSig {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+}
+
+func TestSignMultisigTx(t *testing.T) {
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	wallet := Address{0xcc}
+	tx := NewTx(&MultisigTx{ChainID: 1, From: wallet, To: &Address{0xaa}, Nonce: 1, Gas: 27000})
+	if _, err := Sender(1, tx); err != ErrInvalidSig {
+		t.Errorf("unsigned tx: error mismatch: have %v, want %v", err, ErrInvalidSig)
+	}
+	// every owner adds a signature over the same hash
+	signed, _ := SignTx(tx, 1, key1)
+	signed, _ = SignTx(signed, 1, key2)
+	from, err := Sender(1, signed)
+	if err != nil || from != wallet {
+		t.Fatalf("sender mismatch: have %x, %v, want %x", from, err, wallet)
+	}
+	signers, err := Signers(1, signed)
+	if err != nil || len(signers) != 2 ||
+		signers[0] != PubKeyToAddress(crypto.FromECDSAPub(&key1.PublicKey)) ||
+		signers[1] != PubKeyToAddress(crypto.FromECDSAPub(&key2.PublicKey)) {
+		t.Errorf("signers mismatch: have %x, %v", signers, err)
+	}
+	if signers, _ := Signers(1, NewTx(&LegacyTx{})); signers != nil {
+		t.Errorf("signers of a plain transaction: have %x, want nil", signers)
+	}
+
+	// more signatures than owners are rejected before recovering any
+	inner := signed.inner.copy().(*MultisigTx)
+	for len(inner.Signatures) <= MaxSignatures {
+		inner.Signatures = append(inner.Signatures, inner.Signatures[0])
+	}
+	if _, err := Sender(1, NewTx(inner)); !errors.Is(err, ErrTooManySignatures) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrTooManySignatures)
+	}
+}
@@ -0,0 +1,290 @@
+package types
+
+import (
+	"bytes"
+	"cxchain223/crypto"
+	"cxchain223/crypto/sha3"
+	"cxchain223/utils/hash"
+	"cxchain223/utils/hexutil"
+	"cxchain223/utils/math"
+	"cxchain223/utils/rlp"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestTransactionCost(t *testing.T) {
+	max := new(uint256.Int).SetAllOne()
+	for i, test := range []struct {
+		gas          uint64
+		price, value *uint256.Int
+		cost         *uint256.Int
+		overflow     bool
+	}{
+		{21000, uint256.NewInt(2), uint256.NewInt(100), uint256.NewInt(42100), false},
+		{math.MaxUint64, uint256.NewInt(2), uint256.NewInt(0), new(uint256.Int).Mul(uint256.NewInt(math.MaxUint64), uint256.NewInt(2)), false},
+		{2, max, uint256.NewInt(0), nil, true},
+		{1, uint256.NewInt(1), max, nil, true},
+		{0, uint256.NewInt(0), max, max, false},
+	} {
+		tx := NewTx(&LegacyTx{Gas: test.gas, GasPrice: *test.price, Value: *test.value})
+		cost, overflow := tx.Cost()
+		if overflow != test.overflow {
+			t.Errorf("test %d: overflow mismatch: have %v, want %v", i, overflow, test.overflow)
+		}
+		if !overflow && !cost.Eq(test.cost) {
+			t.Errorf("test %d: cost mismatch: have %v, want %v", i, cost, test.cost)
+		}
+	}
+}
+
+// legacyTxdata is the layout used while values and prices were uint64.
+type legacyTxdata struct {
+	To       Address
+	Nonce    uint64
+	Value    uint64
+	Gas      uint64
+	GasPrice uint64
+	Input    []byte
+}
+
+func TestLegacySigDataRLP(t *testing.T) {
+	legacy := legacyTxdata{
+		To:       Address{0xaa},
+		Nonce:    7,
+		Value:    math.MaxUint64,
+		Gas:      21000,
+		GasPrice: 1000000000,
+		Input:    []byte{1, 2, 3},
+	}
+	enc, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data legacySigData
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		t.Fatalf("can't decode legacy encoding: %v", err)
+	}
+	if data.Value.Uint64() != legacy.Value || data.GasPrice.Uint64() != legacy.GasPrice {
+		t.Errorf("value mismatch: have %v %v", &data.Value, &data.GasPrice)
+	}
+	// the signing payload of existing transactions must not change
+	reenc, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, reenc) {
+		t.Errorf("encoding mismatch:\nhave %x\nwant %x", reenc, enc)
+	}
+}
+
+func TestAccountRLP(t *testing.T) {
+	account := Account{Nonce: 3}
+	account.Amount.Lsh(uint256.NewInt(1), 200)
+	account.CodeHash[0] = 0xcc
+	account.Root[31] = 0xdd
+
+	enc, err := rlp.EncodeToBytes(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := DecodeAccount(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dec != account {
+		t.Errorf("account mismatch: have %+v, want %+v", dec, account)
+	}
+}
+
+func TestDecodeLegacyAccount(t *testing.T) {
+	// Account{Amount: 5, Nonce: 2} as written by the uint64 layout
+	enc := hexutil.MustDecode("0xc40502c0c0")
+	account, err := DecodeAccount(enc)
+	if err != nil {
+		t.Fatalf("can't decode legacy account: %v", err)
+	}
+	if account.Amount.Uint64() != 5 || account.Nonce != 2 {
+		t.Errorf("account mismatch: have %v %d", &account.Amount, account.Nonce)
+	}
+
+	// once stored again the account uses the current layout
+	reenc, _ := rlp.EncodeToBytes(account)
+	if account, err = DecodeAccount(reenc); err != nil || account.Amount.Uint64() != 5 {
+		t.Errorf("can't decode migrated account: %v", err)
+	}
+
+	// legacy hashes were never set, anything else is not a legacy account
+	if _, err := DecodeAccount(hexutil.MustDecode("0xc50502c180c0")); err == nil {
+		t.Error("expected error for malformed account")
+	}
+}
+
+func TestCreateAddress(t *testing.T) {
+	var sender Address
+	copy(sender[:], hexutil.MustDecode("0x970e8128ab834e8eac17ab8e3812f010678cf791"))
+	for nonce, want := range []string{
+		"0x333c3310824b7c685133f2bedb2ca4b8b4df633d",
+		"0x8bda78331c916a08481428e4b07c96d3e916d165",
+	} {
+		if have := CreateAddress(sender, uint64(nonce)); hexutil.Encode(have[:]) != want {
+			t.Errorf("nonce %d: address mismatch: have %x, want %s", nonce, have, want)
+		}
+	}
+}
+
+func TestTxCreationRLP(t *testing.T) {
+	enc, err := rlp.EncodeToBytes(NewTx(&LegacyTx{Nonce: 1, Data: []byte{0x60}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx Transaction
+	if err := rlp.DecodeBytes(enc, &tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.To() != nil {
+		t.Errorf("creation recipient mismatch: have %x, want nil", tx.To())
+	}
+}
+
+func TestEffectiveGasTip(t *testing.T) {
+	legacy := NewTx(&LegacyTx{GasPrice: *uint256.NewInt(10)})
+	dynamic := NewTx(&DynamicFeeTx{GasFeeCap: *uint256.NewInt(10), GasTipCap: *uint256.NewInt(2)})
+	for i, test := range []struct {
+		tx      *Transaction
+		baseFee uint64
+		tip     uint64
+		err     error
+	}{
+		{legacy, 0, 10, nil},
+		{legacy, 7, 3, nil},
+		{legacy, 11, 0, ErrGasFeeCapTooLow},
+		{dynamic, 7, 2, nil},
+		{dynamic, 9, 1, nil},
+		{dynamic, 11, 0, ErrGasFeeCapTooLow},
+	} {
+		tip, err := test.tx.EffectiveGasTip(uint256.NewInt(test.baseFee))
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+			continue
+		}
+		if err == nil && tip.Uint64() != test.tip {
+			t.Errorf("test %d: tip mismatch: have %v, want %d", i, tip, test.tip)
+		}
+	}
+	if cost, _ := dynamic.Cost(); cost.Uint64() != 0 {
+		t.Errorf("cost without gas mismatch: have %v", cost)
+	}
+}
+
+func testTxs() []*Transaction {
+	to := Address{0xaa}
+	al := AccessList{{Address: Address{0xbb}, StorageKeys: []hash.Hash{{1}, {2}}}}
+	return []*Transaction{
+		NewTx(&LegacyTx{To: &to, Nonce: 1, Value: *uint256.NewInt(5), Gas: 21000, GasPrice: *uint256.NewInt(3), Data: []byte{1}}),
+		NewTx(&AccessListTx{Nonce: 2, GasPrice: *uint256.NewInt(3), Gas: 60000, AccessList: al, Data: []byte{0x60}}),
+		NewTx(&DynamicFeeTx{To: &to, Nonce: 3, GasTipCap: *uint256.NewInt(2), GasFeeCap: *uint256.NewInt(10), Gas: 25000, AccessList: al}),
+		NewTx(&MultisigTx{From: Address{0xcc}, To: &to, Nonce: 4, GasPrice: *uint256.NewInt(3), Gas: 27000}),
+	}
+}
+
+func TestTxEncoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	for _, unsigned := range testTxs() {
+		tx, err := SignTx(unsigned, 7, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := tx.MarshalBinary()
+		if tx.Type() != LegacyTxType && want[0] != tx.Type() {
+			t.Errorf("type %d: type byte mismatch: have %d", tx.Type(), want[0])
+		}
+
+		var fromRLP, fromBinary, fromJSON Transaction
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rlp.DecodeBytes(enc, &fromRLP); err != nil {
+			t.Fatalf("type %d: rlp: %v", tx.Type(), err)
+		}
+		if err := fromBinary.UnmarshalBinary(want); err != nil {
+			t.Fatalf("type %d: binary: %v", tx.Type(), err)
+		}
+		js, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(js, &fromJSON); err != nil {
+			t.Fatalf("type %d: json: %v", tx.Type(), err)
+		}
+
+		sender, _ := Sender(7, tx)
+		for name, dec := range map[string]*Transaction{"rlp": &fromRLP, "binary": &fromBinary, "json": &fromJSON} {
+			if have, _ := dec.MarshalBinary(); !bytes.Equal(have, want) {
+				t.Errorf("type %d %s: encoding mismatch:\nhave %x\nwant %x", tx.Type(), name, have, want)
+			}
+			if dec.Hash() != tx.Hash() {
+				t.Errorf("type %d %s: hash mismatch: have %x, want %x", tx.Type(), name, dec.Hash(), tx.Hash())
+			}
+			if from, err := Sender(7, dec); err != nil || from != sender {
+				t.Errorf("type %d %s: sender mismatch: have %x, %v, want %x", tx.Type(), name, from, err, sender)
+			}
+		}
+	}
+
+	var tx Transaction
+	if err := tx.UnmarshalBinary([]byte{0x7f, 0xc0}); err != ErrTxTypeNotSupported {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+func TestTxHashSize(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx := testTxs()[2]
+	signed, err := SignTx(tx, 7, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := signed.MarshalBinary()
+	if signed.Hash() != sha3.Keccak256(enc) {
+		t.Errorf("hash mismatch: have %x, want %x", signed.Hash(), sha3.Keccak256(enc))
+	}
+	if signed.Size() != uint64(len(enc)) {
+		t.Errorf("size mismatch: have %d, want %d", signed.Size(), len(enc))
+	}
+	// the signature is part of the hash
+	if tx.Hash() == signed.Hash() {
+		t.Error("unsigned and signed transaction share a hash")
+	}
+
+	js, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"hash":"` + signed.Hash().Hex() + `"`,
+		`"type":"0x2"`,
+		`"nonce":"0x3"`,
+		`"maxFeePerGas":"0xa"`,
+		`"to":"0xaa00000000000000000000000000000000000000"`,
+	} {
+		if !strings.Contains(string(js), want) {
+			t.Errorf("json %s misses %s", js, want)
+		}
+	}
+}
+
+func TestTxSigHashType(t *testing.T) {
+	// the same fields signed as different types must not share a signature
+	to := Address{0xaa}
+	legacy := NewTx(&LegacyTx{To: &to, Nonce: 1, Gas: 21000, GasPrice: *uint256.NewInt(1)})
+	accessList := NewTx(&AccessListTx{To: &to, Nonce: 1, Gas: 21000, GasPrice: *uint256.NewInt(1)})
+	h1, _ := legacy.sigHash()
+	h2, _ := accessList.sigHash()
+	if bytes.Equal(h1, h2) {
+		t.Error("legacy and access list transactions share a signing hash")
+	}
+}
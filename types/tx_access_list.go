@@ -0,0 +1,107 @@
+package types
+
+import (
+	"bytes"
+	"cxchain223/utils/hash"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// AccessList is the list of accounts and storage slots a transaction
+// declares it is going to touch.
+type AccessList []AccessTuple
+
+// AccessTuple is an account and the storage slots of it in an AccessList.
+type AccessTuple struct {
+	Address     Address     `json:"address"`
+	StorageKeys []hash.Hash `json:"storageKeys"`
+}
+
+// StorageKeys returns the number of storage slots in the access list.
+func (al AccessList) StorageKeys() int {
+	sum := 0
+	for _, tuple := range al {
+		sum += len(tuple.StorageKeys)
+	}
+	return sum
+}
+
+func (al AccessList) copy() AccessList {
+	if al == nil {
+		return nil
+	}
+	cpy := make(AccessList, len(al))
+	for i, tuple := range al {
+		cpy[i] = AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: append([]hash.Hash(nil), tuple.StorageKeys...),
+		}
+	}
+	return cpy
+}
+
+// AccessListTx is a transaction paying GasPrice per gas and carrying an
+// access list.
+type AccessListTx struct {
+	ChainID    uint64
+	Nonce      uint64
+	GasPrice   uint256.Int
+	Gas        uint64
+	To         *Address `rlp:"nil"` // nil means contract creation
+	Value      uint256.Int
+	Data       []byte
+	AccessList AccessList
+
+	V    uint8 // recovery id, 0 or 1
+	R, S *big.Int
+}
+
+func (tx *AccessListTx) copy() TxData {
+	return &AccessListTx{
+		ChainID:    tx.ChainID,
+		Nonce:      tx.Nonce,
+		GasPrice:   tx.GasPrice,
+		Gas:        tx.Gas,
+		To:         copyAddress(tx.To),
+		Value:      tx.Value,
+		Data:       bytes.Clone(tx.Data),
+		AccessList: tx.AccessList.copy(),
+		V:          tx.V,
+		R:          copyBig(tx.R),
+		S:          copyBig(tx.S),
+	}
+}
+
+func (tx *AccessListTx) txType() byte            { return AccessListTxType }
+func (tx *AccessListTx) chainID() uint64         { return tx.ChainID }
+func (tx *AccessListTx) accessList() AccessList  { return tx.AccessList }
+func (tx *AccessListTx) data() []byte            { return tx.Data }
+func (tx *AccessListTx) gas() uint64             { return tx.Gas }
+func (tx *AccessListTx) gasPrice() *uint256.Int  { return &tx.GasPrice }
+func (tx *AccessListTx) gasTipCap() *uint256.Int { return &tx.GasPrice }
+func (tx *AccessListTx) gasFeeCap() *uint256.Int { return &tx.GasPrice }
+func (tx *AccessListTx) value() *uint256.Int     { return &tx.Value }
+func (tx *AccessListTx) nonce() uint64           { return tx.Nonce }
+func (tx *AccessListTx) to() *Address            { return tx.To }
+
+func (tx *AccessListTx) rawSignatureValues() (v uint8, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *AccessListTx) setSignatureValues(chainID uint64, v uint8, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+func (tx *AccessListTx) sigHashData() interface{} {
+	return []interface{}{
+		tx.ChainID,
+		tx.Nonce,
+		&tx.GasPrice,
+		tx.Gas,
+		tx.To,
+		&tx.Value,
+		tx.Data,
+		tx.AccessList,
+	}
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// DynamicFeeTx is a transaction paying the block base fee plus up to
+// GasTipCap per gas, and never more than GasFeeCap in total.
+type DynamicFeeTx struct {
+	ChainID    uint64
+	Nonce      uint64
+	GasTipCap  uint256.Int
+	GasFeeCap  uint256.Int
+	Gas        uint64
+	To         *Address `rlp:"nil"` // nil means contract creation
+	Value      uint256.Int
+	Data       []byte
+	AccessList AccessList
+
+	V    uint8 // recovery id, 0 or 1
+	R, S *big.Int
+}
+
+func (tx *DynamicFeeTx) copy() TxData {
+	return &DynamicFeeTx{
+		ChainID:    tx.ChainID,
+		Nonce:      tx.Nonce,
+		GasTipCap:  tx.GasTipCap,
+		GasFeeCap:  tx.GasFeeCap,
+		Gas:        tx.Gas,
+		To:         copyAddress(tx.To),
+		Value:      tx.Value,
+		Data:       bytes.Clone(tx.Data),
+		AccessList: tx.AccessList.copy(),
+		V:          tx.V,
+		R:          copyBig(tx.R),
+		S:          copyBig(tx.S),
+	}
+}
+
+func (tx *DynamicFeeTx) txType() byte            { return DynamicFeeTxType }
+func (tx *DynamicFeeTx) chainID() uint64         { return tx.ChainID }
+func (tx *DynamicFeeTx) accessList() AccessList  { return tx.AccessList }
+func (tx *DynamicFeeTx) data() []byte            { return tx.Data }
+func (tx *DynamicFeeTx) gas() uint64             { return tx.Gas }
+func (tx *DynamicFeeTx) gasPrice() *uint256.Int  { return &tx.GasFeeCap }
+func (tx *DynamicFeeTx) gasTipCap() *uint256.Int { return &tx.GasTipCap }
+func (tx *DynamicFeeTx) gasFeeCap() *uint256.Int { return &tx.GasFeeCap }
+func (tx *DynamicFeeTx) value() *uint256.Int     { return &tx.Value }
+func (tx *DynamicFeeTx) nonce() uint64           { return tx.Nonce }
+func (tx *DynamicFeeTx) to() *Address            { return tx.To }
+
+func (tx *DynamicFeeTx) rawSignatureValues() (v uint8, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *DynamicFeeTx) setSignatureValues(chainID uint64, v uint8, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+func (tx *DynamicFeeTx) sigHashData() interface{} {
+	return []interface{}{
+		tx.ChainID,
+		tx.Nonce,
+		&tx.GasTipCap,
+		&tx.GasFeeCap,
+		tx.Gas,
+		tx.To,
+		&tx.Value,
+		tx.Data,
+		tx.AccessList,
+	}
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// LegacyTx is a plain transaction paying GasPrice per gas. ChainID is
+// signed along with the rest, so it is only valid on the chain it was
+// signed for.
+type LegacyTx struct {
+	To       *Address `rlp:"nil"` // nil means contract creation
+	Nonce    uint64
+	Value    uint256.Int
+	Gas      uint64
+	GasPrice uint256.Int
+	Data     []byte
+	ChainID  uint64
+
+	V    uint8 // recovery id, 0 or 1
+	R, S *big.Int
+}
+
+// legacySigData is what the sender of a LegacyTx signs. Without a chain ID
+// it is the payload transactions were signed with before chain IDs.
+type legacySigData struct {
+	To       *Address `rlp:"nil"`
+	Nonce    uint64
+	Value    uint256.Int
+	Gas      uint64
+	GasPrice uint256.Int
+	Data     []byte
+	ChainID  uint64 `rlp:"optional"`
+}
+
+func (tx *LegacyTx) copy() TxData {
+	return &LegacyTx{
+		To:       copyAddress(tx.To),
+		Nonce:    tx.Nonce,
+		Value:    tx.Value,
+		Gas:      tx.Gas,
+		GasPrice: tx.GasPrice,
+		Data:     bytes.Clone(tx.Data),
+		ChainID:  tx.ChainID,
+		V:        tx.V,
+		R:        copyBig(tx.R),
+		S:        copyBig(tx.S),
+	}
+}
+
+func (tx *LegacyTx) txType() byte            { return LegacyTxType }
+func (tx *LegacyTx) chainID() uint64         { return tx.ChainID }
+func (tx *LegacyTx) accessList() AccessList  { return nil }
+func (tx *LegacyTx) data() []byte            { return tx.Data }
+func (tx *LegacyTx) gas() uint64             { return tx.Gas }
+func (tx *LegacyTx) gasPrice() *uint256.Int  { return &tx.GasPrice }
+func (tx *LegacyTx) gasTipCap() *uint256.Int { return &tx.GasPrice }
+func (tx *LegacyTx) gasFeeCap() *uint256.Int { return &tx.GasPrice }
+func (tx *LegacyTx) value() *uint256.Int     { return &tx.Value }
+func (tx *LegacyTx) nonce() uint64           { return tx.Nonce }
+func (tx *LegacyTx) to() *Address            { return tx.To }
+
+func (tx *LegacyTx) rawSignatureValues() (v uint8, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *LegacyTx) setSignatureValues(chainID uint64, v uint8, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+func (tx *LegacyTx) sigHashData() interface{} {
+	return legacySigData{
+		To:       tx.To,
+		Nonce:    tx.Nonce,
+		Value:    tx.Value,
+		Gas:      tx.Gas,
+		GasPrice: tx.GasPrice,
+		Data:     tx.Data,
+		ChainID:  tx.ChainID,
+	}
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/holiman/uint256"
+)
+
+// MaxSignatures is the most signatures a MultisigTx may carry, as many as
+// a multisig account may have owners.
+const MaxSignatures = 32
+
+// Signature is a secp256k1 signature, V being the recovery id 0 or 1.
+type Signature struct {
+	V    uint8
+	R, S *big.Int
+}
+
+// MultisigTx is a transaction sent from the multisig account From, paying
+// GasPrice per gas. It carries a signature of each approving owner over the
+// same signing hash, the state machine checks they meet the threshold of
+// From. Signing a MultisigTx adds a signature rather than replacing it.
+type MultisigTx struct {
+	ChainID    uint64
+	Nonce      uint64
+	GasPrice   uint256.Int
+	Gas        uint64
+	From       Address
+	To         *Address `rlp:"nil"` // nil means contract creation
+	Value      uint256.Int
+	Data       []byte
+	Signatures []Signature
+}
+
+func (tx *MultisigTx) copy() TxData {
+	cpy := &MultisigTx{
+		ChainID:  tx.ChainID,
+		Nonce:    tx.Nonce,
+		GasPrice: tx.GasPrice,
+		Gas:      tx.Gas,
+		From:     tx.From,
+		To:       copyAddress(tx.To),
+		Value:    tx.Value,
+		Data:     bytes.Clone(tx.Data),
+	}
+	if tx.Signatures != nil {
+		cpy.Signatures = make([]Signature, len(tx.Signatures))
+		for i, sig := range tx.Signatures {
+			cpy.Signatures[i] = Signature{V: sig.V, R: copyBig(sig.R), S: copyBig(sig.S)}
+		}
+	}
+	return cpy
+}
+
+func (tx *MultisigTx) txType() byte            { return MultisigTxType }
+func (tx *MultisigTx) chainID() uint64         { return tx.ChainID }
+func (tx *MultisigTx) accessList() AccessList  { return nil }
+func (tx *MultisigTx) data() []byte            { return tx.Data }
+func (tx *MultisigTx) gas() uint64             { return tx.Gas }
+func (tx *MultisigTx) gasPrice() *uint256.Int  { return &tx.GasPrice }
+func (tx *MultisigTx) gasTipCap() *uint256.Int { return &tx.GasPrice }
+func (tx *MultisigTx) gasFeeCap() *uint256.Int { return &tx.GasPrice }
+func (tx *MultisigTx) value() *uint256.Int     { return &tx.Value }
+func (tx *MultisigTx) nonce() uint64           { return tx.Nonce }
+func (tx *MultisigTx) to() *Address            { return tx.To }
+
+// rawSignatureValues is empty, the signatures are in Signatures.
+func (tx *MultisigTx) rawSignatureValues() (v uint8, r, s *big.Int) {
+	return 0, nil, nil
+}
+
+// setSignatureValues appends the signature, a nil r only sets the chain.
+func (tx *MultisigTx) setSignatureValues(chainID uint64, v uint8, r, s *big.Int) {
+	tx.ChainID = chainID
+	if r != nil {
+		tx.Signatures = append(tx.Signatures, Signature{V: v, R: r, S: s})
+	}
+}
+
+func (tx *MultisigTx) sigHashData() interface{} {
+	return []interface{}{
+		tx.ChainID,
+		tx.Nonce,
+		&tx.GasPrice,
+		tx.Gas,
+		tx.From,
+		tx.To,
+		&tx.Value,
+		tx.Data,
+	}
+}
@@ -0,0 +1,72 @@
+package vm
+
+import (
+	"cxchain223/types"
+
+	"github.com/holiman/uint256"
+)
+
+// Contract is a piece of code together with the call it is run for.
+type Contract struct {
+	Caller  types.Address
+	Address types.Address
+	Value   uint256.Int
+	Input   []byte
+	Code    []byte
+	Gas     uint64
+
+	jumpdests []bool
+}
+
+func NewContract(caller, address types.Address, value uint256.Int, input, code []byte, gas uint64) *Contract {
+	return &Contract{
+		Caller:  caller,
+		Address: address,
+		Value:   value,
+		Input:   input,
+		Code:    code,
+		Gas:     gas,
+	}
+}
+
+func (c *Contract) GetOp(n uint64) OpCode {
+	if n < uint64(len(c.Code)) {
+		return OpCode(c.Code[n])
+	}
+	return STOP
+}
+
+// UseGas deducts gas and reports whether there was enough.
+func (c *Contract) UseGas(gas uint64) bool {
+	if c.Gas < gas {
+		return false
+	}
+	c.Gas -= gas
+	return true
+}
+
+// validJumpdest checks that dest is a JUMPDEST instruction and not part of
+// the data of a PUSH.
+func (c *Contract) validJumpdest(dest *uint256.Int) bool {
+	udest, overflow := dest.Uint64WithOverflow()
+	if overflow || udest >= uint64(len(c.Code)) {
+		return false
+	}
+	if c.jumpdests == nil {
+		c.jumpdests = analyseJumpdests(c.Code)
+	}
+	return c.jumpdests[udest]
+}
+
+func analyseJumpdests(code []byte) []bool {
+	dests := make([]bool, len(code))
+	for pc := 0; pc < len(code); pc++ {
+		op := OpCode(code[pc])
+		if op == JUMPDEST {
+			dests[pc] = true
+		} else if op.IsPush() {
+			pc += int(op - PUSH1 + 1)
+		}
+	}
+	return dests
+}
@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"cxchain223/crypto"
+	"cxchain223/types"
+	"cxchain223/utils/math"
+	"math/big"
+)
+
+// PrecompiledContract is a native contract living at a reserved address.
+// Calls to it run Go code instead of bytecode.
+type PrecompiledContract interface {
+	RequiredGas(input []byte) uint64
+	Run(input []byte) ([]byte, error)
+}
+
+var PrecompiledContracts = map[types.Address]PrecompiledContract{
+	types.Address{19: 1}: &ecrecover{},
+	types.Address{19: 2}: &keccak256{},
+	types.Address{19: 3}: &identity{},
+	types.Address{19: 4}: &modexp{},
+}
+
+const (
+	EcrecoverGas        uint64 = 3000
+	Keccak256BaseGas    uint64 = 30
+	Keccak256PerWordGas uint64 = 6
+	IdentityBaseGas     uint64 = 15
+	IdentityPerWordGas  uint64 = 3
+	ModExpBaseGas       uint64 = 200
+	ModExpPerBitGas     uint64 = 50 // per bit of the exponent
+)
+
+// RunPrecompiledContract charges the gas for p and runs it, returning the
+// output and the gas left over.
+func RunPrecompiledContract(p PrecompiledContract, input []byte, gas uint64) ([]byte, uint64, error) {
+	cost := p.RequiredGas(input)
+	if gas < cost {
+		return nil, 0, ErrOutOfGas
+	}
+	ret, err := p.Run(input)
+	return ret, gas - cost, err
+}
+
+func wordGas(data []byte, perWord uint64) uint64 {
+	return (uint64(len(data)) + 31) / 32 * perWord
+}
+
+// ecrecover returns the left padded address that signed a hash, given the
+// 128 byte input hash || v || r || s with v being 27 or 28. Invalid
+// signatures produce no output.
+type ecrecover struct{}
+
+func (c *ecrecover) RequiredGas(input []byte) uint64 {
+	return EcrecoverGas
+}
+
+func (c *ecrecover) Run(input []byte) ([]byte, error) {
+	input = rightPad(input, 128)
+	v := new(big.Int).SetBytes(input[32:64])
+	r := new(big.Int).SetBytes(input[64:96])
+	s := new(big.Int).SetBytes(input[96:128])
+	if !v.IsUint64() || (v.Uint64() != 27 && v.Uint64() != 28) {
+		return nil, nil
+	}
+	recid := byte(v.Uint64() - 27)
+	if !crypto.ValidateSignatureValues(recid, r, s, false) {
+		return nil, nil
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig[:64], input[64:128])
+	sig[crypto.RecoveryIDOffset] = recid
+	pub, err := crypto.Ecrecover(input[:32], sig)
+	if err != nil {
+		return nil, nil
+	}
+	ret := make([]byte, 32)
+	copy(ret[12:], crypto.Keccak256(pub[1:])[12:])
+	return ret, nil
+}
+
+type keccak256 struct{}
+
+func (c *keccak256) RequiredGas(input []byte) uint64 {
+	return Keccak256BaseGas + wordGas(input, Keccak256PerWordGas)
+}
+
+func (c *keccak256) Run(input []byte) ([]byte, error) {
+	return crypto.Keccak256(input), nil
+}
+
+type identity struct{}
+
+func (c *identity) RequiredGas(input []byte) uint64 {
+	return IdentityBaseGas + wordGas(input, IdentityPerWordGas)
+}
+
+func (c *identity) Run(input []byte) ([]byte, error) {
+	return append([]byte(nil), input...), nil
+}
+
+// modexp computes base**exponent % modulus over the 96 byte input
+// base || exponent || modulus. A zero modulus stands for 2**256, which
+// matches the EXP instruction.
+type modexp struct{}
+
+func (c *modexp) RequiredGas(input []byte) uint64 {
+	input = rightPad(input, 96)
+	exponent := new(big.Int).SetBytes(input[32:64])
+	return ModExpBaseGas + uint64(exponent.BitLen())*ModExpPerBitGas
+}
+
+func (c *modexp) Run(input []byte) ([]byte, error) {
+	input = rightPad(input, 96)
+	base := new(big.Int).SetBytes(input[:32])
+	exponent := new(big.Int).SetBytes(input[32:64])
+	modulus := new(big.Int).SetBytes(input[64:96])
+
+	var result *big.Int
+	if modulus.Sign() == 0 {
+		result = math.Exp(base, exponent)
+	} else {
+		result = new(big.Int).Exp(base, exponent, modulus)
+	}
+	return math.PaddedBigBytes(result, 32), nil
+}
+
+// rightPad returns data zero padded to at least size bytes.
+func rightPad(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded, data)
+	return padded
+}
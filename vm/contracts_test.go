@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"bytes"
+	"cxchain223/crypto"
+	"testing"
+)
+
+func TestEcrecover(t *testing.T) {
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := crypto.Keccak256([]byte("cxchain"))
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make([]byte, 128)
+	copy(input, digest)
+	input[63] = sig[64] + 27
+	copy(input[64:], sig[:64])
+
+	want := make([]byte, 32)
+	copy(want[12:], crypto.Keccak256(crypto.FromECDSAPub(&key.PublicKey)[1:])[12:])
+
+	p := &ecrecover{}
+	if ret, _ := p.Run(input); !bytes.Equal(ret, want) {
+		t.Errorf("address mismatch: have %x, want %x", ret, want)
+	}
+	// a bad recovery id yields no output
+	input[63] = 29
+	if ret, err := p.Run(input); ret != nil || err != nil {
+		t.Errorf("invalid signature: have %x, %v", ret, err)
+	}
+}
+
+func TestPrecompiles(t *testing.T) {
+	modexpInput := func(base, exponent, modulus uint64) []byte {
+		return append(append(word(base), word(exponent)...), word(modulus)...)
+	}
+	maxWord := bytes.Repeat([]byte{0xff}, 32)
+	for i, test := range []struct {
+		p     PrecompiledContract
+		input []byte
+		ret   []byte
+		gas   uint64
+	}{
+		{&keccak256{}, nil, crypto.Keccak256(nil), Keccak256BaseGas},
+		{&keccak256{}, []byte("abc"), crypto.Keccak256([]byte("abc")), Keccak256BaseGas + Keccak256PerWordGas},
+		{&identity{}, []byte{1, 2, 3}, []byte{1, 2, 3}, IdentityBaseGas + IdentityPerWordGas},
+		{&identity{}, make([]byte, 33), make([]byte, 33), IdentityBaseGas + 2*IdentityPerWordGas},
+		{&modexp{}, modexpInput(3, 5, 7), word(5), ModExpBaseGas + 3*ModExpPerBitGas},
+		{&modexp{}, modexpInput(2, 10, 0), word(1024), ModExpBaseGas + 4*ModExpPerBitGas},
+		{&modexp{}, modexpInput(7, 0, 0), word(1), ModExpBaseGas},
+		// a zero modulus wraps at 2**256
+		{&modexp{}, append(append(maxWord, word(2)...), word(0)...), word(1), ModExpBaseGas + 2*ModExpPerBitGas},
+		// short input is zero padded
+		{&modexp{}, word(9)[:32], word(1), ModExpBaseGas},
+	} {
+		ret, err := test.p.Run(test.input)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(ret, test.ret) {
+			t.Errorf("test %d: output mismatch: have %x, want %x", i, ret, test.ret)
+		}
+		if gas := test.p.RequiredGas(test.input); gas != test.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, test.gas)
+		}
+	}
+}
+
+func TestRunPrecompiledContract(t *testing.T) {
+	p := &identity{}
+	ret, gas, err := RunPrecompiledContract(p, []byte{1}, 100)
+	if err != nil || !bytes.Equal(ret, []byte{1}) || gas != 100-IdentityBaseGas-IdentityPerWordGas {
+		t.Errorf("have %x, %d, %v", ret, gas, err)
+	}
+	if _, gas, err := RunPrecompiledContract(p, []byte{1}, 17); err != ErrOutOfGas || gas != 0 {
+		t.Errorf("out of gas: have %d, %v", gas, err)
+	}
+}
@@ -0,0 +1,17 @@
+package vm
+
+import "errors"
+
+var (
+	ErrOutOfGas          = errors.New("out of gas")
+	ErrStackUnderflow    = errors.New("stack underflow")
+	ErrStackOverflow     = errors.New("stack limit reached")
+	ErrInvalidJump       = errors.New("invalid jump destination")
+	ErrInvalidOpCode     = errors.New("invalid opcode")
+	ErrGasUintOverflow   = errors.New("gas uint64 overflow")
+	ErrExecutionReverted = errors.New("execution reverted")
+
+	ErrContractAddressCollision = errors.New("contract address collision")
+	ErrMaxCodeSizeExceeded      = errors.New("max code size exceeded")
+	ErrCodeStoreOutOfGas        = errors.New("contract creation code storage out of gas")
+)
@@ -0,0 +1,72 @@
+package vm
+
+const (
+	GasQuickStep   uint64 = 2
+	GasFastestStep uint64 = 3
+	GasFastStep    uint64 = 5
+	GasMidStep     uint64 = 8
+	GasSlowStep    uint64 = 10
+	GasJumpDest    uint64 = 1
+
+	SloadGas       uint64 = 800   // reading a storage slot
+	SstoreSetGas   uint64 = 20000 // writing a slot that was zero
+	SstoreResetGas uint64 = 5000  // writing a slot that was not zero
+	MemoryGas      uint64 = 3     // per word of memory, on top of the quadratic part
+	QuadCoeffDiv   uint64 = 512
+	CreateDataGas  uint64 = 200 // per byte of deployed code
+	LogGas         uint64 = 375
+	LogTopicGas    uint64 = 375 // per topic
+	LogDataGas     uint64 = 8   // per byte of data
+
+	// MaxCodeSize is the largest code a contract creation may deploy.
+	MaxCodeSize = 24576
+
+	// maxMemorySize is the largest memory whose cost still fits a uint64.
+	maxMemorySize uint64 = 0x1FFFFFFFE0
+)
+
+// constantGas is the static part of the cost of each operation. Memory
+// expansion and storage writes are charged on top.
+func constantGas(op OpCode) uint64 {
+	switch {
+	case op.IsPush(), op >= DUP1 && op <= DUP16, op >= SWAP1 && op <= SWAP16:
+		return GasFastestStep
+	case op >= LOG0 && op <= LOG4:
+		return LogGas + uint64(op-LOG0)*LogTopicGas
+	}
+	switch op {
+	case STOP, RETURN, REVERT, SSTORE:
+		return 0
+	case JUMPDEST:
+		return GasJumpDest
+	case CALLER, CALLVALUE, CALLDATASIZE, POP, PC, GAS:
+		return GasQuickStep
+	case ADD, SUB, LT, GT, EQ, ISZERO, AND, OR, XOR, NOT, CALLDATALOAD, MLOAD, MSTORE:
+		return GasFastestStep
+	case MUL, DIV, MOD:
+		return GasFastStep
+	case JUMP:
+		return GasMidStep
+	case JUMPI:
+		return GasSlowStep
+	case SLOAD:
+		return SloadGas
+	}
+	return 0
+}
+
+// memoryGasCost returns the gas needed to grow memory from size bytes to
+// newSize bytes.
+func memoryGasCost(size, newSize uint64) (uint64, error) {
+	if newSize <= size {
+		return 0, nil
+	}
+	if newSize > maxMemorySize {
+		return 0, ErrGasUintOverflow
+	}
+	cost := func(bytes uint64) uint64 {
+		words := (bytes + 31) / 32
+		return words*MemoryGas + words*words/QuadCoeffDiv
+	}
+	return cost(newSize) - cost(size), nil
+}
@@ -0,0 +1,321 @@
+package vm
+
+import (
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+
+	"github.com/holiman/uint256"
+)
+
+// StateDB is the part of the state contracts can access.
+type StateDB interface {
+	LoadStorage(addr types.Address, key hash.Hash) hash.Hash
+	StoreStorage(addr types.Address, key, value hash.Hash)
+	AddLog(log *types.Log)
+}
+
+type Interpreter struct {
+	Tracer Tracer // optional
+
+	state StateDB
+}
+
+func NewInterpreter(state StateDB) *Interpreter {
+	return &Interpreter{
+		state: state,
+	}
+}
+
+// Run executes the code of contract until it stops, returns or fails,
+// deducting the gas spent from contract.Gas. A REVERT returns its data
+// together with ErrExecutionReverted. On any other error all gas is
+// consumed.
+func (in *Interpreter) Run(contract *Contract) (ret []byte, err error) {
+	var (
+		stack = newStack()
+		mem   = newMemory()
+		pc    uint64
+		op    OpCode
+	)
+	defer func() {
+		if err != nil && err != ErrExecutionReverted {
+			if in.Tracer != nil {
+				in.Tracer.CaptureFault(pc, op, contract.Gas, contract, err)
+			}
+			contract.Gas = 0
+		}
+	}()
+
+	for {
+		op = contract.GetOp(pc)
+		pops, pushes, ok := stackRequirements(op)
+		if !ok {
+			return nil, ErrInvalidOpCode
+		}
+		if stack.Len() < pops {
+			return nil, ErrStackUnderflow
+		}
+		if stack.Len()-pops+pushes > stackLimit {
+			return nil, ErrStackOverflow
+		}
+		gasBefore := contract.Gas
+		if !contract.UseGas(constantGas(op)) {
+			return nil, ErrOutOfGas
+		}
+
+		// grow memory for the operations touching it
+		var offset, size uint64
+		switch op {
+		case MLOAD, MSTORE:
+			offset, size, err = memoryRange(stack.Back(0), uint256.NewInt(32))
+		case RETURN, REVERT, LOG0, LOG1, LOG2, LOG3, LOG4:
+			offset, size, err = memoryRange(stack.Back(0), stack.Back(1))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			newSize := (offset + size + 31) / 32 * 32
+			gas, err := memoryGasCost(uint64(mem.Len()), newSize)
+			if err != nil {
+				return nil, err
+			}
+			if !contract.UseGas(gas) {
+				return nil, ErrOutOfGas
+			}
+			mem.resize(newSize)
+		}
+
+		// the remaining dynamic gas, so the tracer sees the full cost
+		var dynamic uint64
+		switch {
+		case op >= LOG0 && op <= LOG4:
+			dynamic = size * LogDataGas
+		case op == SSTORE:
+			dynamic = SstoreResetGas
+			loc, val := stack.Back(0), stack.Back(1)
+			if current := in.state.LoadStorage(contract.Address, loc.Bytes32()); current == (hash.Hash{}) && !val.IsZero() {
+				dynamic = SstoreSetGas
+			}
+		}
+		if !contract.UseGas(dynamic) {
+			return nil, ErrOutOfGas
+		}
+		if in.Tracer != nil {
+			in.Tracer.CaptureState(pc, op, gasBefore, gasBefore-contract.Gas, stack, mem, contract)
+		}
+
+		switch {
+		case op.IsPush():
+			n := uint64(op - PUSH1 + 1)
+			start := min(pc+1, uint64(len(contract.Code)))
+			end := min(pc+1+n, uint64(len(contract.Code)))
+			// push data running past the end of the code is zero padded
+			data := make([]byte, n)
+			copy(data, contract.Code[start:end])
+			stack.push(new(uint256.Int).SetBytes(data))
+			pc += n + 1
+			continue
+		case op >= DUP1 && op <= DUP16:
+			stack.dup(int(op - DUP1 + 1))
+			pc++
+			continue
+		case op >= SWAP1 && op <= SWAP16:
+			stack.swap(int(op - SWAP1 + 1))
+			pc++
+			continue
+		case op >= LOG0 && op <= LOG4:
+			stack.pop()
+			stack.pop()
+			topics := make([]hash.Hash, op-LOG0)
+			for i := range topics {
+				topic := stack.pop()
+				topics[i] = topic.Bytes32()
+			}
+			in.state.AddLog(&types.Log{
+				Address: contract.Address,
+				Topics:  topics,
+				Data:    mem.getCopy(offset, size),
+			})
+			pc++
+			continue
+		}
+
+		switch op {
+		case STOP:
+			return nil, nil
+		case ADD:
+			x := stack.pop()
+			y := stack.peek()
+			y.Add(&x, y)
+		case MUL:
+			x := stack.pop()
+			y := stack.peek()
+			y.Mul(&x, y)
+		case SUB:
+			x := stack.pop()
+			y := stack.peek()
+			y.Sub(&x, y)
+		case DIV:
+			x := stack.pop()
+			y := stack.peek()
+			y.Div(&x, y)
+		case MOD:
+			x := stack.pop()
+			y := stack.peek()
+			y.Mod(&x, y)
+		case LT:
+			x := stack.pop()
+			y := stack.peek()
+			setBool(y, x.Lt(y))
+		case GT:
+			x := stack.pop()
+			y := stack.peek()
+			setBool(y, x.Gt(y))
+		case EQ:
+			x := stack.pop()
+			y := stack.peek()
+			setBool(y, x.Eq(y))
+		case ISZERO:
+			x := stack.peek()
+			setBool(x, x.IsZero())
+		case AND:
+			x := stack.pop()
+			y := stack.peek()
+			y.And(&x, y)
+		case OR:
+			x := stack.pop()
+			y := stack.peek()
+			y.Or(&x, y)
+		case XOR:
+			x := stack.pop()
+			y := stack.peek()
+			y.Xor(&x, y)
+		case NOT:
+			x := stack.peek()
+			x.Not(x)
+		case CALLER:
+			stack.push(new(uint256.Int).SetBytes(contract.Caller[:]))
+		case CALLVALUE:
+			stack.push(&contract.Value)
+		case CALLDATALOAD:
+			x := stack.peek()
+			x.SetBytes(getData(contract.Input, x, 32))
+		case CALLDATASIZE:
+			stack.push(uint256.NewInt(uint64(len(contract.Input))))
+		case POP:
+			stack.pop()
+		case MLOAD:
+			stack.peek().SetBytes(mem.getCopy(offset, 32))
+		case MSTORE:
+			stack.pop()
+			val := stack.pop()
+			mem.set32(offset, &val)
+		case SLOAD:
+			loc := stack.peek()
+			val := in.state.LoadStorage(contract.Address, hash.Hash(loc.Bytes32()))
+			loc.SetBytes(val[:])
+		case SSTORE:
+			loc := stack.pop()
+			val := stack.pop()
+			in.state.StoreStorage(contract.Address, loc.Bytes32(), val.Bytes32())
+		case JUMP:
+			dest := stack.pop()
+			if !contract.validJumpdest(&dest) {
+				return nil, ErrInvalidJump
+			}
+			pc = dest.Uint64()
+			continue
+		case JUMPI:
+			dest, cond := stack.pop(), stack.pop()
+			if !cond.IsZero() {
+				if !contract.validJumpdest(&dest) {
+					return nil, ErrInvalidJump
+				}
+				pc = dest.Uint64()
+				continue
+			}
+		case PC:
+			stack.push(uint256.NewInt(pc))
+		case GAS:
+			stack.push(uint256.NewInt(contract.Gas))
+		case JUMPDEST:
+		case RETURN:
+			stack.pop()
+			stack.pop()
+			return mem.getCopy(offset, size), nil
+		case REVERT:
+			stack.pop()
+			stack.pop()
+			return mem.getCopy(offset, size), ErrExecutionReverted
+		}
+		pc++
+	}
+}
+
+// stackRequirements returns how many items op pops and pushes, and false
+// if op is not a valid instruction.
+func stackRequirements(op OpCode) (pops, pushes int, ok bool) {
+	switch {
+	case op.IsPush():
+		return 0, 1, true
+	case op >= DUP1 && op <= DUP16:
+		n := int(op - DUP1 + 1)
+		return n, n + 1, true
+	case op >= SWAP1 && op <= SWAP16:
+		n := int(op - SWAP1 + 2)
+		return n, n, true
+	case op >= LOG0 && op <= LOG4:
+		return 2 + int(op-LOG0), 0, true
+	}
+	switch op {
+	case STOP, JUMPDEST:
+		return 0, 0, true
+	case ADD, MUL, SUB, DIV, MOD, LT, GT, EQ, AND, OR, XOR:
+		return 2, 1, true
+	case ISZERO, NOT, CALLDATALOAD, MLOAD, SLOAD:
+		return 1, 1, true
+	case CALLER, CALLVALUE, CALLDATASIZE, PC, GAS:
+		return 0, 1, true
+	case POP, JUMP:
+		return 1, 0, true
+	case MSTORE, SSTORE, JUMPI, RETURN, REVERT:
+		return 2, 0, true
+	}
+	return 0, 0, false
+}
+
+// memoryRange converts the offset and size operands of a memory access.
+func memoryRange(offset, size *uint256.Int) (uint64, uint64, error) {
+	if size.IsZero() {
+		return 0, 0, nil
+	}
+	off, overflow := offset.Uint64WithOverflow()
+	if overflow {
+		return 0, 0, ErrGasUintOverflow
+	}
+	length, overflow := size.Uint64WithOverflow()
+	if overflow || off > maxMemorySize || length > maxMemorySize-off {
+		return 0, 0, ErrGasUintOverflow
+	}
+	return off, length, nil
+}
+
+// getData returns size bytes of data starting at start, zero padded.
+func getData(data []byte, start *uint256.Int, size uint64) []byte {
+	ret := make([]byte, size)
+	offset, overflow := start.Uint64WithOverflow()
+	if overflow || offset >= uint64(len(data)) {
+		return ret
+	}
+	copy(ret, data[offset:])
+	return ret
+}
+
+func setBool(x *uint256.Int, b bool) {
+	if b {
+		x.SetOne()
+	} else {
+		x.Clear()
+	}
+}
@@ -0,0 +1,147 @@
+package vm
+
+import (
+	"bytes"
+	"cxchain223/statdb"
+	"cxchain223/types"
+	"cxchain223/utils/hash"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// returnTop appends code returning the top of the stack as a 32 byte word.
+func returnTop(code ...byte) []byte {
+	return append(code,
+		byte(PUSH1), 0, byte(MSTORE),
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	)
+}
+
+func word(x uint64) []byte {
+	b := uint256.NewInt(x).Bytes32()
+	return b[:]
+}
+
+func TestInterpreter(t *testing.T) {
+	for i, test := range []struct {
+		code []byte
+		ret  []byte
+		err  error
+	}{
+		// 7 - 3, operands are taken from the top of the stack first
+		{returnTop(byte(PUSH1), 3, byte(PUSH1), 7, byte(SUB)), word(4), nil},
+		{returnTop(byte(PUSH1), 3, byte(PUSH1), 7, byte(DIV)), word(2), nil},
+		{returnTop(byte(PUSH1), 3, byte(PUSH1), 7, byte(MOD)), word(1), nil},
+		{returnTop(byte(PUSH1), 0, byte(PUSH1), 7, byte(DIV)), word(0), nil},
+		{returnTop(byte(PUSH1), 3, byte(PUSH1), 7, byte(GT)), word(1), nil},
+		{returnTop(byte(PUSH1), 3, byte(PUSH1), 7, byte(LT)), word(0), nil},
+		{returnTop(byte(PUSH1), 7, byte(DUP1), byte(EQ), byte(ISZERO)), word(0), nil},
+		{returnTop(byte(PUSH1), 1, byte(PUSH1), 2, byte(SWAP1), byte(POP)), word(2), nil},
+		{returnTop(byte(CALLVALUE)), word(5), nil},
+		{returnTop(byte(PUSH1), 0, byte(CALLDATALOAD)), word(0x2a), nil},
+		{returnTop(byte(CALLDATASIZE)), word(32), nil},
+		// jump over an invalid opcode
+		{returnTop(byte(PUSH1), 4, byte(JUMP), 0xfe, byte(JUMPDEST), byte(PC)), word(5), nil},
+		// conditional jump not taken
+		{returnTop(byte(PUSH1), 0, byte(PUSH1), 7, byte(JUMPI), byte(PUSH1), 9, byte(JUMPDEST)), word(9), nil},
+		{[]byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(REVERT)}, nil, ErrExecutionReverted},
+		{[]byte{byte(STOP)}, nil, nil},
+		{nil, nil, nil},
+		{[]byte{byte(ADD)}, nil, ErrStackUnderflow},
+		{[]byte{0xfe}, nil, ErrInvalidOpCode},
+		// the destination is push data, not an instruction
+		{[]byte{byte(PUSH1), 3, byte(JUMP), byte(PUSH1), byte(JUMPDEST)}, nil, ErrInvalidJump},
+		{[]byte{byte(PUSH1), 100, byte(JUMP)}, nil, ErrInvalidJump},
+		// endless loop
+		{[]byte{byte(JUMPDEST), byte(PUSH1), 0, byte(JUMP)}, nil, ErrOutOfGas},
+		// huge memory offset
+		{[]byte{byte(PUSH1), 1, byte(PUSH32), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, byte(MSTORE)}, nil, ErrGasUintOverflow},
+	} {
+		contract := NewContract(types.Address{1}, types.Address{2}, *uint256.NewInt(5), word(0x2a), test.code, 100000)
+		ret, err := NewInterpreter(statdb.NewMemoryDB()).Run(contract)
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if !bytes.Equal(ret, test.ret) {
+			t.Errorf("test %d: return mismatch: have %x, want %x", i, ret, test.ret)
+		}
+		if err != nil && err != ErrExecutionReverted && contract.Gas != 0 {
+			t.Errorf("test %d: gas left after failure: %d", i, contract.Gas)
+		}
+	}
+}
+
+func TestInterpreterStackOverflow(t *testing.T) {
+	code := make([]byte, 0, 2*(stackLimit+1))
+	for i := 0; i <= stackLimit; i++ {
+		code = append(code, byte(PUSH1), 1)
+	}
+	contract := NewContract(types.Address{}, types.Address{}, uint256.Int{}, nil, code, 100000)
+	if _, err := NewInterpreter(statdb.NewMemoryDB()).Run(contract); err != ErrStackOverflow {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrStackOverflow)
+	}
+}
+
+func TestInterpreterStorage(t *testing.T) {
+	var (
+		state = statdb.NewMemoryDB()
+		addr  = types.Address{2}
+		code  = []byte{
+			byte(PUSH1), 7, byte(PUSH1), 1, byte(SSTORE),
+			byte(PUSH1), 1, byte(SLOAD), byte(PUSH1), 2, byte(SSTORE),
+		}
+	)
+	contract := NewContract(types.Address{1}, addr, uint256.Int{}, nil, code, 100000)
+	if _, err := NewInterpreter(state).Run(contract); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, slot := range []uint64{1, 2} {
+		if have := state.LoadStorage(addr, hash.BytesToHash(word(slot))); have != hash.BytesToHash(word(7)) {
+			t.Errorf("slot %d mismatch: have %x", slot, have)
+		}
+	}
+	want := 100000 - 4*GasFastestStep - 2*SstoreSetGas - SloadGas
+	if contract.Gas != want {
+		t.Errorf("gas left mismatch: have %d, want %d", contract.Gas, want)
+	}
+
+	// writing again only costs the reset price
+	contract = NewContract(types.Address{1}, addr, uint256.Int{}, nil, code, 100000)
+	NewInterpreter(state).Run(contract)
+	if want := 100000 - 4*GasFastestStep - 2*SstoreResetGas - SloadGas; contract.Gas != want {
+		t.Errorf("gas left mismatch: have %d, want %d", contract.Gas, want)
+	}
+}
+
+func TestInterpreterLog(t *testing.T) {
+	var (
+		state = statdb.NewMemoryDB()
+		addr  = types.Address{2}
+		// LOG2 with data = CALLVALUE and topics 7, CALLER
+		code = []byte{
+			byte(CALLVALUE), byte(PUSH1), 0, byte(MSTORE),
+			byte(CALLER), byte(PUSH1), 7, byte(PUSH1), 32, byte(PUSH1), 0, byte(LOG2),
+		}
+		caller = types.Address{1}
+	)
+	contract := NewContract(caller, addr, *uint256.NewInt(5), nil, code, 100000)
+	if _, err := NewInterpreter(state).Run(contract); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logs := state.Logs()
+	if len(logs) != 1 {
+		t.Fatalf("log count mismatch: have %d, want 1", len(logs))
+	}
+	log := logs[0]
+	if log.Address != addr || !bytes.Equal(log.Data, word(5)) {
+		t.Errorf("log mismatch: address %x data %x", log.Address, log.Data)
+	}
+	if len(log.Topics) != 2 || log.Topics[0] != hash.BytesToHash(word(7)) || log.Topics[1] != hash.BytesToHash(caller[:]) {
+		t.Errorf("topics mismatch: %x", log.Topics)
+	}
+	want := 100000 - 5*GasFastestStep - 2*GasQuickStep - 3 - LogGas - 2*LogTopicGas - 32*LogDataGas
+	if contract.Gas != want {
+		t.Errorf("gas left mismatch: have %d, want %d", contract.Gas, want)
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import "github.com/holiman/uint256"
+
+// Memory is the byte addressable scratch space of a single call. It grows
+// in 32 byte words.
+type Memory struct {
+	store []byte
+}
+
+func newMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) resize(size uint64) {
+	if uint64(len(m.store)) < size {
+		m.store = append(m.store, make([]byte, size-uint64(len(m.store)))...)
+	}
+}
+
+func (m *Memory) set(offset, size uint64, value []byte) {
+	if size > 0 {
+		copy(m.store[offset:offset+size], value)
+	}
+}
+
+func (m *Memory) set32(offset uint64, val *uint256.Int) {
+	val.WriteToSlice(m.store[offset : offset+32])
+}
+
+// getCopy returns a copy of size bytes starting at offset.
+func (m *Memory) getCopy(offset, size uint64) []byte {
+	if size == 0 {
+		return nil
+	}
+	cpy := make([]byte, size)
+	copy(cpy, m.store[offset:offset+size])
+	return cpy
+}
+
+func (m *Memory) Len() int {
+	return len(m.store)
+}
+
+func (m *Memory) Data() []byte {
+	return m.store
+}
@@ -0,0 +1,51 @@
+package vm
+
+import "github.com/holiman/uint256"
+
+const stackLimit = 1024
+
+type Stack struct {
+	data []uint256.Int
+}
+
+func newStack() *Stack {
+	return &Stack{data: make([]uint256.Int, 0, 16)}
+}
+
+func (st *Stack) push(d *uint256.Int) {
+	st.data = append(st.data, *d)
+}
+
+func (st *Stack) pop() uint256.Int {
+	ret := st.data[len(st.data)-1]
+	st.data = st.data[:len(st.data)-1]
+	return ret
+}
+
+// peek returns the top of the stack, which can be overwritten in place.
+func (st *Stack) peek() *uint256.Int {
+	return &st.data[len(st.data)-1]
+}
+
+// Back returns the n'th item counted from the top of the stack.
+func (st *Stack) Back(n int) *uint256.Int {
+	return &st.data[len(st.data)-n-1]
+}
+
+func (st *Stack) dup(n int) {
+	st.push(&st.data[len(st.data)-n])
+}
+
+func (st *Stack) swap(n int) {
+	top := len(st.data) - 1
+	st.data[top], st.data[top-n] = st.data[top-n], st.data[top]
+}
+
+func (st *Stack) Len() int {
+	return len(st.data)
+}
+
+// Data returns the stack, bottom first.
+func (st *Stack) Data() []uint256.Int {
+	return st.data
+}
@@ -0,0 +1,11 @@
+package vm
+
+// Tracer is notified of the instructions the interpreter executes.
+type Tracer interface {
+	// CaptureState is called before op runs, with the gas left before op
+	// and the full cost charged for it.
+	CaptureState(pc uint64, op OpCode, gas, cost uint64, stack *Stack, memory *Memory, contract *Contract)
+	// CaptureFault is called when op fails with an error other than a
+	// revert.
+	CaptureFault(pc uint64, op OpCode, gas uint64, contract *Contract, err error)
+}